@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"strings"
 
 	"github.com/spf13/cast"
 )
@@ -14,17 +15,58 @@ const (
 	hrArg                 argTypeEnum = "*http.request"
 	rwArg                 argTypeEnum = "http.ResponseWriter"
 	ctxArg                argTypeEnum = "context.Context"
+	ginCtxArg             argTypeEnum = "*gin.Context"
 	customizeStructArg    argTypeEnum = "customizeStruct"
 	customizeStructPrtArg argTypeEnum = "customizeStructPrtArg"
 	fileHeader            argTypeEnum = "*multipart.fileHeader"
 	multiFile             argTypeEnum = "*multipart.Form"
 	basicArg              argTypeEnum = "basic"
 	basicSliceArg         argTypeEnum = "basicSlice"
+	basicMapArg           argTypeEnum = "basicMap"
+	injectArg             argTypeEnum = "inject"
+	txArg                 argTypeEnum = "transaction"
+	ctxValueArg           argTypeEnum = "contextValue"
+	principalArg          argTypeEnum = "principal"
+	credentialsArg        argTypeEnum = "credentials"
+	claimsArg             argTypeEnum = "claims"
+	rawBodyArg            argTypeEnum = "[]byte"
+	bodyReaderArg         argTypeEnum = "io.Reader"
+	uploadStreamArg       argTypeEnum = "*gbinding.UploadStream"
 )
 
 type argTypeInfo struct {
 	argType     reflect.Type
 	argTypeEnum argTypeEnum
+
+	//paramFields 结构体上带有uri以及header tag的字段
+	paramFields []paramField
+	//bindBody 结构体上有uri以及header tag以外的字段时，需要交给gin从body以及url上绑定
+	bindBody bool
+	//primary 通过WithPathNames等选项设置了名称的字段绑定到该结构体上
+	primary bool
+	//provider 通过Provide注册的类型的提供者
+	provider providerFunc
+	//contextKey 通过WithContextValue设置的key
+	contextKey interface{}
+	//sanitizers 结构体上带有sanitize tag的字段
+	sanitizers []sanitizeField
+	//enums 结构体上类型实现了Enum的字段
+	enums []enumField
+	//styles 结构体上设置了style的字段
+	styles []structQueryStyle
+}
+
+//paramField 结构体上通过tag声明来源的字段
+type paramField struct {
+	index  []int
+	source Source
+	name   string
+	//omitEmpty 只对ctx生效，key不存在时不返回错误
+	omitEmpty bool
+	//fileConstraint 文件字段上通过tag设置的约束
+	fileConstraint *FileConstraint
+	//stream *StoredFile字段，流式保存到Storage
+	stream bool
 }
 
 func (a *argTypeInfo) GetBasicType() reflect.Type {
@@ -38,14 +80,6 @@ func (a *argTypeInfo) String() string {
 	return string(a.argTypeEnum)
 }
 
-func (a *argTypeInfo) ValidSecondArgType() bool {
-	return a.argTypeEnum != hrArg && a.argTypeEnum != ctxArg
-}
-
-func (a *argTypeInfo) ValidFirstArgType() bool {
-	return a.argTypeEnum == hrArg || a.argTypeEnum == ctxArg
-}
-
 func (a *argTypeInfo) IsHttpRequest() bool {
 	return a.argTypeEnum == hrArg
 }
@@ -54,6 +88,28 @@ func (a *argTypeInfo) IsResponseWriter() bool {
 	return a.argTypeEnum == rwArg
 }
 
+func (a *argTypeInfo) IsTx() bool {
+	return a.argTypeEnum == txArg
+}
+
+func (a *argTypeInfo) IsGinContext() bool {
+	return a.argTypeEnum == ginCtxArg
+}
+
+//IsBindValue 需要从请求中绑定数据的参数
+func (a *argTypeInfo) IsBindValue() bool {
+	switch a.argTypeEnum {
+	case hrArg, rwArg, ctxArg, ginCtxArg, injectArg, txArg, ctxValueArg, principalArg, credentialsArg, claimsArg:
+		return false
+	}
+	return true
+}
+
+//IsNamedValue basic,slice以及map参数共用WithQueryName等选项设置的名称，一个函数只能有一个
+func (a *argTypeInfo) IsNamedValue() bool {
+	return a.argTypeEnum == basicArg || a.argTypeEnum == basicSliceArg || a.argTypeEnum == basicMapArg
+}
+
 func (a *argTypeInfo) IsCustomizeStructBind() bool {
 	return a.argTypeEnum == customizeStructArg || a.argTypeEnum == customizeStructPrtArg
 }
@@ -74,30 +130,87 @@ func (a *argTypeInfo) IsBasicSlice() bool {
 	return a.argTypeEnum == basicSliceArg
 }
 
+//HasStreamFields 结构体上有流式保存的*StoredFile字段
+func (a *argTypeInfo) HasStreamFields() bool {
+	for i := range a.paramFields {
+		if a.paramFields[i].stream {
+			return true
+		}
+	}
+	return false
+}
+
+//HasPartFields 结构体上有带part tag的字段
+func (a *argTypeInfo) HasPartFields() bool {
+	for i := range a.paramFields {
+		if a.paramFields[i].source == Part {
+			return true
+		}
+	}
+	return false
+}
+
+//IsBody 直接读取body的参数
+func (a *argTypeInfo) IsBody() bool {
+	return a.argTypeEnum == rawBodyArg || a.argTypeEnum == bodyReaderArg || a.argTypeEnum == uploadStreamArg
+}
+
+func (a *argTypeInfo) IsBasicMap() bool {
+	return a.argTypeEnum == basicMapArg
+}
+
+//isBasicKind 是否是可以直接从字符串转换过来的基础类型
+func isBasicKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.Float32, reflect.Float64, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+//isBasicMapType 支持 map[string]basicType 以及 map[string][]basicType
+func isBasicMapType(t reflect.Type) bool {
+	if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String {
+		return false
+	}
+	elem := t.Elem()
+	if elem.Kind() == reflect.Slice {
+		elem = elem.Elem()
+	}
+	return isBasicKind(elem.Kind())
+}
+
+//invalidValue 值无法转换成字段的类型
+func invalidValue(field reflect.Value, value string, err error) error {
+	return &BindError{Kind: KindInvalidValue, Type: field.Type().String(), Value: value, Err: err}
+}
+
 func setBasicValue(field reflect.Value, value string) error {
 	switch field.Kind() {
 	case reflect.Bool:
 		e, err := cast.ToBoolE(value)
 		if err != nil {
-			return err
+			return invalidValue(field, value, err)
 		}
 		field.SetBool(e)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		e, err := cast.ToInt64E(value)
 		if err != nil {
-			return err
+			return invalidValue(field, value, err)
 		}
 		field.SetInt(e)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		e, err := cast.ToUint64E(value)
 		if err != nil {
-			return err
+			return invalidValue(field, value, err)
 		}
 		field.SetUint(e)
 	case reflect.Float32, reflect.Float64:
 		e, err := cast.ToFloat64E(value)
 		if err != nil {
-			return err
+			return invalidValue(field, value, err)
 		}
 		field.SetFloat(e)
 	case reflect.String:
@@ -114,40 +227,40 @@ func setBasicSlice(slice reflect.Value, elemKind reflect.Kind, value []string) e
 		for i := range value {
 			v := value[i]
 			e, err := cast.ToBoolE(v)
+			index := slice.Index(i)
 			if err != nil {
-				return err
+				return invalidValue(index, v, err)
 			}
-			index := slice.Index(i)
 			index.SetBool(e)
 		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		for i := range value {
 			v := value[i]
 			e, err := cast.ToInt64E(v)
+			index := slice.Index(i)
 			if err != nil {
-				return err
+				return invalidValue(index, v, err)
 			}
-			index := slice.Index(i)
 			index.SetInt(e)
 		}
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		for i := range value {
 			v := value[i]
 			e, err := cast.ToUint64E(v)
+			index := slice.Index(i)
 			if err != nil {
-				return err
+				return invalidValue(index, v, err)
 			}
-			index := slice.Index(i)
 			index.SetUint(e)
 		}
 	case reflect.Float32, reflect.Float64:
 		for i := range value {
 			v := value[i]
 			e, err := cast.ToFloat64E(v)
+			index := slice.Index(i)
 			if err != nil {
-				return err
+				return invalidValue(index, v, err)
 			}
-			index := slice.Index(i)
 			index.SetFloat(e)
 		}
 	case reflect.String:
@@ -162,10 +275,104 @@ func setBasicSlice(slice reflect.Value, elemKind reflect.Kind, value []string) e
 	return nil
 }
 
+//setBasicMap 将收集到的多值数据写入map,元素是slice时保留全部值,否则只取第一个值
+func setBasicMap(mapValue reflect.Value, values map[string][]string) error {
+	mapType := mapValue.Type()
+	elemType := mapType.Elem()
+	for k, v := range values {
+		if len(v) == 0 {
+			continue
+		}
+		var elem reflect.Value
+		if elemType.Kind() == reflect.Slice {
+			elem = reflect.MakeSlice(elemType, len(v), len(v))
+			if err := setBasicSlice(elem, elemType.Elem().Kind(), v); err != nil {
+				return err
+			}
+		} else {
+			elem = reflect.New(elemType).Elem()
+			if err := setBasicValue(elem, v[0]); err != nil {
+				return err
+			}
+		}
+		mapValue.SetMapIndex(reflect.ValueOf(k).Convert(mapType.Key()), elem)
+	}
+	return nil
+}
+
+//structParamFields 找出结构体上带有uri,header,ctx,auth,claim以及part tag的字段以及文件字段，只要有其他导出字段就需要从body以及url上绑定
+func structParamFields(structType reflect.Type) ([]paramField, bool) {
+	var (
+		fields   []paramField
+		bindBody bool
+	)
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		if isStoredFileField(field.Type) {
+			fields = append(fields, paramField{index: field.Index, source: File, name: formKey(field), fileConstraint: fileTagConstraint(structType, field), stream: true})
+			bindBody = true
+			continue
+		}
+		if isFileField(field.Type) {
+			fields = append(fields, paramField{index: field.Index, source: File, name: formKey(field), fileConstraint: fileTagConstraint(structType, field)})
+			continue
+		}
+		if name := tagName(field, "uri"); name != "" {
+			fields = append(fields, paramField{index: field.Index, source: Path, name: name})
+			continue
+		}
+		if name := tagName(field, "header"); name != "" {
+			fields = append(fields, paramField{index: field.Index, source: Header, name: name})
+			continue
+		}
+		if name := tagName(field, "ctx"); name != "" {
+			omitEmpty := strings.Contains(field.Tag.Get("ctx"), ",omitempty")
+			fields = append(fields, paramField{index: field.Index, source: Context, name: name, omitEmpty: omitEmpty})
+			continue
+		}
+		if name := tagName(field, "auth"); name != "" {
+			fields = append(fields, paramField{index: field.Index, source: Auth, name: name})
+			continue
+		}
+		if name := tagName(field, "claim"); name != "" {
+			fields = append(fields, paramField{index: field.Index, source: Claim, name: name})
+			continue
+		}
+		if name := tagName(field, "part"); name != "" {
+			fields = append(fields, paramField{index: field.Index, source: Part, name: name})
+			continue
+		}
+		bindBody = true
+	}
+	return fields, bindBody
+}
+
+//tagName 取出tag中的名称，忽略逗号后面的选项
+func tagName(field reflect.StructField, tag string) string {
+	name := strings.Split(field.Tag.Get(tag), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
 func toArgTypeEnum(arg reflect.Type) *argTypeInfo {
 	result := &argTypeInfo{
 		argType: arg,
 	}
+	//注册过的类型优先注入
+	if principal != nil && arg == principal.principalType {
+		result.argTypeEnum = principalArg
+		return result
+	}
+	if provider, ok := providers[arg]; ok {
+		result.argTypeEnum = injectArg
+		result.provider = provider
+		return result
+	}
 	switch arg {
 	case httpRequestType:
 		result.argTypeEnum = hrArg
@@ -173,6 +380,16 @@ func toArgTypeEnum(arg reflect.Type) *argTypeInfo {
 		result.argTypeEnum = rwArg
 	case contextType:
 		result.argTypeEnum = ctxArg
+	case ginContextType:
+		result.argTypeEnum = ginCtxArg
+	case credentialsType:
+		result.argTypeEnum = credentialsArg
+	case bytesType, rawMessageType:
+		result.argTypeEnum = rawBodyArg
+	case readerType, readCloserType:
+		result.argTypeEnum = bodyReaderArg
+	case uploadStreamType:
+		result.argTypeEnum = uploadStreamArg
 	default:
 		switch arg.Kind() {
 		case reflect.Ptr:
@@ -186,9 +403,15 @@ func toArgTypeEnum(arg reflect.Type) *argTypeInfo {
 				result.argTypeEnum = multiFile
 			default:
 				result.argTypeEnum = customizeStructPrtArg
+				result.paramFields, result.bindBody = structParamFields(arg.Elem())
+				result.sanitizers = structSanitizers(arg.Elem())
+				result.enums = structEnums(arg.Elem())
 			}
 		case reflect.Struct:
 			result.argTypeEnum = customizeStructArg
+			result.paramFields, result.bindBody = structParamFields(arg)
+			result.sanitizers = structSanitizers(arg)
+			result.enums = structEnums(arg)
 		case reflect.Slice:
 			elem := arg.Elem()
 			switch elem.Kind() {
@@ -199,6 +422,11 @@ func toArgTypeEnum(arg reflect.Type) *argTypeInfo {
 				log.Panicf("only support basic type slice,but this slice elem type is %s", elem.String())
 			}
 			result.argTypeEnum = basicSliceArg
+		case reflect.Map:
+			if !isBasicMapType(arg) {
+				log.Panicf("only support map[string]basicType or map[string][]basicType,but get %s", arg.String())
+			}
+			result.argTypeEnum = basicMapArg
 		case reflect.Bool, reflect.Float32, reflect.Float64, reflect.String,
 			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
@@ -2,11 +2,14 @@ package gbinding
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"reflect"
 	"testing"
 
+	"github.com/gin-gonic/gin"
 	"github.com/go-playground/assert/v2"
 )
 
@@ -26,6 +29,11 @@ func Test_toArgTypeEnum(t *testing.T) {
 		assert.Equal(t, typeInfo.argTypeEnum, ctxArg)
 	})
 
+	t.Run("*gin.Context", func(t *testing.T) {
+		typeInfo := toArgTypeEnum(reflect.TypeOf(&gin.Context{}))
+		assert.Equal(t, typeInfo.argTypeEnum, ginCtxArg)
+	})
+
 	t.Run("CustomizeStruct", func(t *testing.T) {
 		typeInfo := toArgTypeEnum(reflect.TypeOf(CustomerStruct{}))
 		assert.Equal(t, typeInfo.argTypeEnum, customizeStructArg)
@@ -100,11 +108,61 @@ func Test_toArgTypeEnum(t *testing.T) {
 			toArgTypeEnum(reflect.TypeOf([]CustomerStruct{}))
 		})
 	})
+
+	t.Run("rawBody", func(t *testing.T) {
+		typeInfo := toArgTypeEnum(reflect.TypeOf([]byte{}))
+		assert.Equal(t, typeInfo.argTypeEnum, rawBodyArg)
+		typeInfo = toArgTypeEnum(reflect.TypeOf(json.RawMessage{}))
+		assert.Equal(t, typeInfo.argTypeEnum, rawBodyArg)
+		typeInfo = toArgTypeEnum(reflect.TypeOf((*io.Reader)(nil)).Elem())
+		assert.Equal(t, typeInfo.argTypeEnum, bodyReaderArg)
+	})
+
+	t.Run("basicMap", func(t *testing.T) {
+		t.Run("string", func(t *testing.T) {
+			typeInfo := toArgTypeEnum(reflect.TypeOf(map[string]string{}))
+			assert.Equal(t, typeInfo.argTypeEnum, basicMapArg)
+		})
+		t.Run("stringSlice", func(t *testing.T) {
+			typeInfo := toArgTypeEnum(reflect.TypeOf(map[string][]string{}))
+			assert.Equal(t, typeInfo.argTypeEnum, basicMapArg)
+		})
+		t.Run("int", func(t *testing.T) {
+			typeInfo := toArgTypeEnum(reflect.TypeOf(map[string]int{}))
+			assert.Equal(t, typeInfo.argTypeEnum, basicMapArg)
+		})
+
+		t.Run("notStringKey", func(t *testing.T) {
+			defer func() {
+				i := recover()
+				assert.Equal(t, i.(string), "only support map[string]basicType or map[string][]basicType,but get map[int]string")
+			}()
+			toArgTypeEnum(reflect.TypeOf(map[int]string{}))
+		})
+	})
 }
 
 type CustomerStruct struct {
 }
 
+func Test_structParamFields(t *testing.T) {
+	type params struct {
+		ID    int    `uri:"id"`
+		Trace string `header:"X-Trace-Id,omitempty"`
+		Name  string `json:"name"`
+		skip  string
+	}
+	fields, bindBody := structParamFields(reflect.TypeOf(params{}))
+	assert.Equal(t, bindBody, true)
+	assert.Equal(t, fields, []paramField{
+		{index: []int{0}, source: Path, name: "id"},
+		{index: []int{1}, source: Header, name: "X-Trace-Id"},
+	})
+
+	_, bindBody = structParamFields(reflect.TypeOf(CustomerStruct{}))
+	assert.Equal(t, bindBody, false)
+}
+
 func Test_setBasicSlice(t *testing.T) {
 	type args struct {
 		slice    reflect.Value
@@ -127,6 +185,26 @@ func Test_setBasicSlice(t *testing.T) {
 	}
 }
 
+func Test_setBasicMap(t *testing.T) {
+	t.Run("first value", func(t *testing.T) {
+		m := map[string]int{}
+		err := setBasicMap(reflect.ValueOf(m), map[string][]string{"a": {"1", "2"}})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, m, map[string]int{"a": 1})
+	})
+	t.Run("all value", func(t *testing.T) {
+		m := map[string][]int{}
+		err := setBasicMap(reflect.ValueOf(m), map[string][]string{"a": {"1", "2"}})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, m, map[string][]int{"a": {1, 2}})
+	})
+	t.Run("cast error", func(t *testing.T) {
+		m := map[string]int{}
+		err := setBasicMap(reflect.ValueOf(m), map[string][]string{"a": {"x"}})
+		assert.NotEqual(t, err, nil)
+	})
+}
+
 func Test_setBasicValue(t *testing.T) {
 	type args struct {
 		field reflect.Value
@@ -3,7 +3,10 @@ package gbinding
 import (
 	"fmt"
 	"log"
+	"net/textproto"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -16,6 +19,41 @@ type argsInfo struct {
 	headerNames []string
 	cookieNames []string
 
+	//queryMapNames 以 name[key]=value 的形式从url或者post的form上收集map
+	queryMapNames []string
+	//headerPrefixes 收集header中带有该前缀的全部值
+	headerPrefixes []string
+	//queryStyles url上参数的序列化方式,key为空时是默认值
+	queryStyles map[string]queryStyle
+	//headerQValues 绑定slice时按照Accept的规则解析header中的q值,并按q值从高到低排序
+	headerQValues bool
+	//sources 绑定basic参数时的查找顺序，nil时使用defaultSources
+	sources []Source
+	//strictSources 多个来源都有值且不一致时返回错误
+	strictSources bool
+	//contextKeys 从gin.Context的key或者context.Context的value上获取的名称
+	contextKeys []string
+	//apiKey api key的位置
+	apiKey *apiKeySource
+	//jwt 通过WithJWT设置的校验
+	jwt *jwtConfig
+	//maxBodyBytes 读取body时的限制，0为不限制
+	maxBodyBytes int64
+	//maxMultipartMemory 解析multipart时保存在内存中的最大字节数，0时使用gin的设置
+	maxMultipartMemory int64
+	//fileConstraints 通过WithFileConstraint设置的上传文件约束，key为空时对全部文件生效
+	fileConstraints map[string]FileConstraint
+	//storage 通过WithStorage设置的流式上传的存储
+	storage Storage
+	//streaming 参数中有流式读取multipart的参数，不能提前解析multipart
+	streaming bool
+	//maxSliceLen slice以及map中值的最大数量，maxQueryParams url上参数的最大数量，maxHeaderValueLen header值的最大长度，0为不限制
+	maxSliceLen       int
+	maxQueryParams    int
+	maxHeaderValueLen int
+	//sanitizers 通过WithSanitizers设置的basic参数的转换
+	sanitizers []sanitizer
+
 	filedNameIsEqual func(fieldName, inputName string) bool
 	args             []*argTypeInfo
 
@@ -27,21 +65,49 @@ type argsInfo struct {
 func (a *argsInfo) checkBindValue(bindingTypeInfo *argTypeInfo) {
 	switch bindingTypeInfo.argTypeEnum {
 	case customizeStructArg, customizeStructPrtArg:
+		a.checkAuthFields(bindingTypeInfo.GetBasicType(), bindingTypeInfo.paramFields)
+		a.checkClaimFields(bindingTypeInfo.GetBasicType(), bindingTypeInfo.paramFields)
+		if bindingTypeInfo.HasStreamFields() && a.storage == nil {
+			log.Panicf("struct:%s has *StoredFile field, must set WithStorage", bindingTypeInfo.GetBasicType().String())
+		}
+		//流式读取时body不会被解析成multipart.Form
+		if bindingTypeInfo.HasStreamFields() {
+			for _, field := range bindingTypeInfo.paramFields {
+				if field.source == File && !field.stream {
+					log.Panicf("struct:%s field:%s *StoredFile fields are streamed, can't use with *multipart.FileHeader field", bindingTypeInfo.GetBasicType().String(), bindingTypeInfo.GetBasicType().FieldByIndex(field.index).Name)
+				}
+			}
+		}
+		bindingTypeInfo.styles = a.structQueryStyles(bindingTypeInfo.GetBasicType())
+		if !bindingTypeInfo.primary {
+			return
+		}
 		structBasicType := bindingTypeInfo.GetBasicType()
 		validValue := reflect.New(structBasicType).Elem()
 		allNeedCheckField := make([]string, 0)
 		allNeedCheckField = append(allNeedCheckField, a.pathNames...)
 		allNeedCheckField = append(allNeedCheckField, a.headerNames...)
 		allNeedCheckField = append(allNeedCheckField, a.cookieNames...)
+		allNeedCheckField = append(allNeedCheckField, a.contextKeys...)
 		a.checkFieldValid(structBasicType, validValue, allNeedCheckField)
+		allNeedCheckMapField := append([]string{}, a.queryMapNames...)
+		for i := range a.headerPrefixes {
+			allNeedCheckMapField = append(allNeedCheckMapField, headerPrefixFieldName(a.headerPrefixes[i]))
+		}
+		a.checkFieldValid(structBasicType, validValue, allNeedCheckMapField)
+		a.checkMapFieldType(structBasicType, allNeedCheckMapField)
 	case basicSliceArg:
-		if len(a.queryName) == 0 {
-			log.Panicf("BasicSlice arg must set queryName")
+		if len(a.queryName) == 0 && len(a.headerNames) == 0 && len(a.cookieNames) == 0 {
+			log.Panicf("BasicSlice arg must set one of name  (queryName,headerNames,cookieNames)")
+		}
+		if a.basicSliceStyle().style == DeepObjectStyle {
+			log.Panicf("BasicSlice arg can't use deepObject style")
 		}
 	case basicArg:
-		if len(a.queryName) == 0 && len(a.pathNames) == 0 && len(a.headerNames) == 0 && len(a.cookieNames) == 0 {
-			log.Panicf("Basic arg must set one of name  (queryName,pathNames,headerNames,cookieNames)")
+		if len(a.queryName) == 0 && len(a.pathNames) == 0 && len(a.headerNames) == 0 && len(a.cookieNames) == 0 && len(a.contextKeys) == 0 {
+			log.Panicf("Basic arg must set one of name  (queryName,pathNames,headerNames,cookieNames,contextKeys)")
 		}
+		a.checkSources()
 	}
 }
 
@@ -52,153 +118,561 @@ func (a *argsInfo) checkFieldValid(structType reflect.Type, structValue reflect.
 		//检查这些字段是否存在
 		for i := range fieldNames {
 			value := fieldNames[i]
-			_, ok := structType.FieldByNameFunc(func(s string) bool {
+			field, ok := structType.FieldByNameFunc(func(s string) bool {
 				return a.filedNameIsEqual(s, value)
 			})
 			if !ok {
 				log.Panicf("struct:%s field:%s no found,please check", structType.String(), value)
 			}
-			if !structValue.FieldByName(value).CanSet() {
+			if !structValue.FieldByName(field.Name).CanSet() {
 				log.Panicf("struct:%s field:%s can't set,please check is export", structType.String(), value)
 			}
 		}
 	}
 }
 
-func (a *argsInfo) binding(gctx *gin.Context, argInfo *argTypeInfo, argValues []reflect.Value) error {
+//checkMapFieldType 绑定map的字段必须是 map[string]basicType 或者 map[string][]basicType
+func (a *argsInfo) checkMapFieldType(structType reflect.Type, fieldNames []string) {
+	for i := range fieldNames {
+		value := fieldNames[i]
+		field, _ := structType.FieldByNameFunc(func(s string) bool {
+			return a.filedNameIsEqual(s, value)
+		})
+		if !isBasicMapType(field.Type) {
+			log.Panicf("struct:%s field:%s expect map[string]basicType or map[string][]basicType but get %s", structType.String(), field.Name, field.Type.String())
+		}
+	}
+}
+
+//argValue 按照参数的类型注入或者从请求中绑定
+func (a *argsInfo) argValue(gctx *gin.Context, argInfo *argTypeInfo) (reflect.Value, error) {
+	switch argInfo.argTypeEnum {
+	case hrArg:
+		return reflect.ValueOf(gctx.Request), nil
+	case rwArg:
+		return reflect.ValueOf(gctx.Writer).Convert(rsWriterType), nil
+	case ctxArg:
+		return reflect.ValueOf(gctx.Request.Context()).Convert(contextType), nil
+	case ginCtxArg:
+		return reflect.ValueOf(gctx), nil
+	case injectArg:
+		return argInfo.provider(gctx)
+	case ctxValueArg:
+		return bindContextValue(gctx, argInfo)
+	case principalArg:
+		_, value, err := currentPrincipal(gctx)
+		return value, err
+	case credentialsArg:
+		credentials, err := a.credentials(gctx)
+		return reflect.ValueOf(credentials), err
+	case claimsArg:
+		return a.jwt.bindClaims(gctx, argInfo.argType)
+	case rawBodyArg:
+		return a.bindRawBody(gctx, argInfo)
+	case bodyReaderArg:
+		return a.bindBodyReader(gctx, argInfo)
+	case uploadStreamArg:
+		return a.bindUploadStream(gctx)
+	}
+	return a.binding(gctx, argInfo)
+}
+
+func (a *argsInfo) binding(gctx *gin.Context, argInfo *argTypeInfo) (reflect.Value, error) {
 	switch argInfo.argTypeEnum {
 	case fileHeader:
 		file, err := gctx.FormFile(a.fileName)
 		if err != nil {
-			return err
+			return reflect.Value{}, err
 		}
-		argValues = append(argValues, reflect.ValueOf(file))
+		fieldErrs, err := checkFiles(a.fileName, a.fileConstraint(a.fileName, nil), gctx.Request.MultipartForm.File[a.fileName])
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if err := fieldErrorsResult(fieldErrs); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(file), nil
 	case multiFile:
 		form, err := gctx.MultipartForm()
 		if err != nil {
-			return err
+			return reflect.Value{}, err
 		}
-		argValues = append(argValues, reflect.ValueOf(form))
-	case customizeStructArg, customizeStructPrtArg:
-		var elemValuePrt reflect.Value
-		if argInfo.argTypeEnum == customizeStructArg {
-			elemValuePrt = reflect.New(argInfo.argType)
-		} else {
-			elemValuePrt = reflect.New(argInfo.argType.Elem())
+		//按名称排序，保证错误的顺序稳定
+		names := make([]string, 0, len(form.File))
+		for name := range form.File {
+			names = append(names, name)
 		}
-
-		if err := gctx.ShouldBind(elemValuePrt.Interface()); err != nil {
-			return err
+		sort.Strings(names)
+		var fieldErrs FieldErrors
+		for _, name := range names {
+			errs, err := checkFiles(name, a.fileConstraint(name, nil), form.File[name])
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			fieldErrs = append(fieldErrs, errs...)
 		}
-
+		if err := fieldErrorsResult(fieldErrs); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(form), nil
+	case customizeStructArg, customizeStructPrtArg:
+		elemValuePrt := reflect.New(argInfo.GetBasicType())
 		elemValue := elemValuePrt.Elem()
-		//如果需要设置绑定uri数据，也帮忙绑定了
-		pathNames := a.pathNames
-		for i := range pathNames {
-			filedValue := elemValue.FieldByNameFunc(func(s string) bool {
-				return a.filedNameIsEqual(s, pathNames[i])
-			})
-			if err := setBasicValue(filedValue, gctx.Param(pathNames[i])); err != nil {
-				return err
+		//按照gin的规则只解码不校验，binding以及validate tag在全部来源绑定完之后由checkStruct统一校验
+		if argInfo.HasStreamFields() {
+			if err := a.bindStream(gctx, argInfo, elemValuePrt); err != nil {
+				return reflect.Value{}, err
+			}
+		} else if argInfo.bindBody {
+			a.normalizeQuery(gctx, argInfo)
+			//body已经缓存时，多个结构体都可以从头读取
+			rewindBody(gctx)
+			if err := a.checkFormLen(gctx); err != nil {
+				return reflect.Value{}, err
+			}
+			obj := elemValuePrt.Interface()
+			if err := decodeBody(gctx.Request, obj); err != nil {
+				return reflect.Value{}, err
+			}
+			if err := a.bindDeepObjects(gctx, argInfo, elemValue); err != nil {
+				return reflect.Value{}, err
 			}
 		}
-
-		//如果需要设置绑定header数据，也帮忙绑定了
-		headerNames := a.headerNames
-		for i := range headerNames {
-			filedValue := elemValue.FieldByNameFunc(func(s string) bool {
-				return a.filedNameIsEqual(s, headerNames[i])
-			})
-
-			if err := setBasicValue(filedValue, gctx.GetHeader(headerNames[i])); err != nil {
-				return nil
+		//没有其他body字段时也需要解码part，gin绑定form时可能覆盖part字段，在gin之后解码
+		if argInfo.HasPartFields() && !argInfo.HasStreamFields() {
+			if err := a.bindPartFields(argInfo, elemValue, multipartPart(gctx)); err != nil {
+				return reflect.Value{}, err
 			}
 		}
+		//gin按照字段名称绑定时可能写入这些字段，在gin之后绑定，body以及url上的同名值不会生效
+		if err := a.bindParamFields(gctx, argInfo, elemValue); err != nil {
+			return reflect.Value{}, err
+		}
+		if err := a.bindContextFields(gctx, argInfo, elemValue); err != nil {
+			return reflect.Value{}, err
+		}
 
-		cookieNames := a.cookieNames
-		for i := range cookieNames {
-			filedValue := elemValue.FieldByNameFunc(func(s string) bool {
-				return a.filedNameIsEqual(s, cookieNames[i])
-			})
-			cookie, err := gctx.Cookie(headerNames[i])
-			if err != nil {
-				return err
-			}
-			if err := setBasicValue(filedValue, cookie); err != nil {
-				return nil
+		//通过名称设置的字段只绑定到主结构体上
+		if argInfo.primary {
+			if err := a.bindNamedFields(gctx, elemValue); err != nil {
+				return reflect.Value{}, err
 			}
 		}
 
+		//全部来源都绑定完之后再整理以及校验
+		if err := a.checkStruct(gctx, argInfo, elemValuePrt); err != nil {
+			return reflect.Value{}, err
+		}
+
 		//用户是需要接收结构体
 		if argInfo.argTypeEnum == customizeStructArg {
-			argValues = append(argValues, elemValuePrt.Elem())
-		} else {
-			argValues = append(argValues, elemValuePrt)
+			return elemValue, nil
 		}
+		return elemValuePrt, nil
 
 	case basicArg:
+		//默认先从url后面取，没有就从post，再没有就从uri，header，cookie上获取
+		data, exist, err := a.lookupBasic(gctx)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if !exist {
+			return reflect.Value{}, a.missingBasic(argInfo)
+		}
+		value := reflect.New(argInfo.argType).Elem()
+		if err := setBasicValue(value, data); err != nil {
+			return reflect.Value{}, withField(err, a.basicName())
+		}
+		a.sanitizeBasic(value)
+		if err := checkEnum(value); err != nil {
+			return reflect.Value{}, withField(err, a.basicName())
+		}
+		return value, nil
+	case basicSliceArg:
 		var (
-			data  string
+			data  []string
 			exist bool
 		)
-		//先从url后面取
+		//和basic一样的顺序，使用第一个取到值的来源，不会合并多个来源的值
 		queryName := a.queryName
 		if queryName != "" {
-			data, exist = gctx.GetQuery(queryName)
-			//没有就从post
+			data, exist = gctx.GetQueryArray(queryName)
 			if !exist {
-				data, exist = gctx.GetPostForm(queryName)
+				data, exist = gctx.GetPostFormArray(queryName)
 			}
+			data = a.basicSliceStyle().split(data)
 		}
 
-		//再没有就从uri上获取
-		if !exist && len(a.pathNames) > 0 {
-			data = gctx.Param(a.pathNames[0])
-			exist = data != ""
-		}
-
-		//支持从header上面获取
+		//header上的全部值，逗号分隔的列表会被拆开
 		if !exist && len(a.headerNames) > 0 {
-			data = gctx.GetHeader(a.headerNames[0])
-			exist = data != ""
+			data = a.headerValues(gctx, a.headerNames[0])
+			exist = len(data) > 0
 		}
 
-		//最后还是没有的话,支持从cookie上面获取
+		//同名的全部cookie
 		if !exist && len(a.cookieNames) > 0 {
-			data, _ = gctx.Cookie(a.cookieNames[0])
-			exist = data != ""
+			data = cookieValues(gctx, a.cookieNames[0])
+			exist = len(data) > 0
 		}
 		if !exist {
-			return fmt.Errorf("try to binding %s,but can't get from url,uri,header,cookie. please check you option name set", argInfo.argType.String())
+			return reflect.Value{}, a.missingBasic(argInfo)
 		}
-		value := reflect.New(argInfo.argType).Elem()
-		if err := setBasicValue(value, data); err != nil {
+		if err := a.checkSliceLen(argInfo.argType.String(), len(data)); err != nil {
+			return reflect.Value{}, err
+		}
+		slice := reflect.MakeSlice(argInfo.argType, len(data), len(data))
+		if err := setBasicSlice(slice, argInfo.argType.Elem().Kind(), data); err != nil {
+			return reflect.Value{}, withField(err, a.basicName())
+		}
+		a.sanitizeBasic(slice)
+		if err := checkEnum(slice); err != nil {
+			return reflect.Value{}, withField(err, a.basicName())
+		}
+		return slice, nil
+	case basicMapArg:
+		var data map[string][]string
+		switch {
+		case len(a.queryMapNames) > 0:
+			data = a.queryMapValues(gctx, a.queryMapNames[0])
+		case len(a.headerPrefixes) > 0:
+			data = headerPrefixValues(gctx, a.headerPrefixes[0])
+		default:
+			//没有设置名称的情况下,收集url以及post的form上的全部参数
+			data = make(map[string][]string)
+			mergeValues(data, gctx.Request.URL.Query())
+			mergeValues(data, postFormValues(gctx))
+		}
+		if err := a.checkMapLen(argInfo.argType.String(), data); err != nil {
+			return reflect.Value{}, err
+		}
+		value := reflect.MakeMapWithSize(argInfo.argType, len(data))
+		if err := setBasicMap(value, data); err != nil {
+			return reflect.Value{}, err
+		}
+		if err := checkEnum(value); err != nil {
+			return reflect.Value{}, err
+		}
+		return value, nil
+	}
+	return reflect.Value{}, fmt.Errorf("unsupport binding arg type %s", argInfo.argType.String())
+}
+
+//bindParamFields 绑定结构体上带有uri,header,auth以及claim tag的字段以及文件字段，请求上没有的字段为零值
+func (a *argsInfo) bindParamFields(gctx *gin.Context, argInfo *argTypeInfo, elemValue reflect.Value) error {
+	var (
+		credentials *Credentials
+		//fileErrs 全部文件字段的错误一起返回
+		fileErrs FieldErrors
+	)
+	for i := range argInfo.paramFields {
+		paramField := argInfo.paramFields[i]
+		filedValue := elemValue.FieldByIndex(paramField.index)
+		switch paramField.source {
+		case Context, Part:
+			//ctx以及part tag的字段单独绑定
+			continue
+		case File:
+			//流式保存的字段在读取body时已经绑定
+			if paramField.stream {
+				continue
+			}
+		}
+		//清除gin按照字段名称写入的值
+		filedValue.Set(reflect.Zero(filedValue.Type()))
+		switch paramField.source {
+		case Path:
+			if data, ok := pathParam(gctx, paramField.name); ok {
+				if err := setBasicValue(filedValue, data); err != nil {
+					return withField(err, paramField.name)
+				}
+			}
+		case Header:
+			if filedValue.Kind() == reflect.Slice {
+				if err := a.setSliceValue(filedValue, paramField.name, a.headerValues(gctx, paramField.name)); err != nil {
+					return err
+				}
+				continue
+			}
+			if data := gctx.Request.Header.Values(paramField.name); len(data) > 0 {
+				if err := setBasicValue(filedValue, data[0]); err != nil {
+					return withField(err, paramField.name)
+				}
+			}
+		case Auth:
+			if credentials == nil {
+				c, err := a.credentials(gctx)
+				if err != nil {
+					return err
+				}
+				credentials = &c
+			}
+			filedValue.SetString(credentialField(*credentials, paramField.name))
+		case Claim:
+			claims, err := a.jwt.claims(gctx)
+			if err != nil {
+				return err
+			}
+			if err := setClaimField(filedValue, claims, paramField.name); err != nil {
+				return err
+			}
+		case File:
+			errs, err := a.bindFileField(gctx, filedValue, paramField)
+			if err != nil {
+				return err
+			}
+			fileErrs = append(fileErrs, errs...)
+		}
+	}
+	return fieldErrorsResult(fileErrs)
+}
+
+//bindNamedFields 绑定通过WithPathNames,WithHeaderNames等选项设置了名称的字段
+func (a *argsInfo) bindNamedFields(gctx *gin.Context, elemValue reflect.Value) error {
+	//如果需要设置绑定uri数据，也帮忙绑定了
+	pathNames := a.pathNames
+	for i := range pathNames {
+		filedValue := elemValue.FieldByNameFunc(func(s string) bool {
+			return a.filedNameIsEqual(s, pathNames[i])
+		})
+		if err := setBasicValue(filedValue, gctx.Param(pathNames[i])); err != nil {
+			return withField(err, pathNames[i])
+		}
+	}
+
+	//如果需要设置绑定header数据，也帮忙绑定了，slice字段会绑定header的全部值，没有该header时为零值
+	headerNames := a.headerNames
+	for i := range headerNames {
+		filedValue := elemValue.FieldByNameFunc(func(s string) bool {
+			return a.filedNameIsEqual(s, headerNames[i])
+		})
+		if filedValue.Kind() == reflect.Slice {
+			if err := a.setSliceValue(filedValue, headerNames[i], a.headerValues(gctx, headerNames[i])); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := gctx.Request.Header[textproto.CanonicalMIMEHeaderKey(headerNames[i])]; !ok {
+			//清除gin按照字段名称从url或者body写入的值
+			filedValue.Set(reflect.Zero(filedValue.Type()))
+			continue
+		}
+		if err := setBasicValue(filedValue, gctx.GetHeader(headerNames[i])); err != nil {
+			return withField(err, headerNames[i])
+		}
+	}
+
+	//slice字段会绑定同名的全部cookie
+	cookieNames := a.cookieNames
+	for i := range cookieNames {
+		filedValue := elemValue.FieldByNameFunc(func(s string) bool {
+			return a.filedNameIsEqual(s, cookieNames[i])
+		})
+		if filedValue.Kind() == reflect.Slice {
+			if err := a.setSliceValue(filedValue, cookieNames[i], cookieValues(gctx, cookieNames[i])); err != nil {
+				return err
+			}
+			continue
+		}
+		cookie, err := gctx.Cookie(cookieNames[i])
+		if err != nil {
 			return err
 		}
-		argValues = append(argValues, value)
-		return nil
-	case basicSliceArg:
-		var (
-			data  []string
-			exist bool
-		)
-		queryName := a.queryName
-		data, exist = gctx.GetQueryArray(queryName)
-		if !exist {
-			data, exist = gctx.GetPostFormArray(queryName)
+		if err := setBasicValue(filedValue, cookie); err != nil {
+			return withField(err, cookieNames[i])
 		}
-		if !exist {
-			return fmt.Errorf("try to binding %s,but can't get from url,uri,header,cookie. please check you option name set", argInfo.argType.String())
+	}
+
+	//map类型的字段,按照 name[key]=value 的形式收集
+	queryMapNames := a.queryMapNames
+	for i := range queryMapNames {
+		filedValue := elemValue.FieldByNameFunc(func(s string) bool {
+			return a.filedNameIsEqual(s, queryMapNames[i])
+		})
+		if err := a.setMapValue(filedValue, queryMapNames[i], a.queryMapValues(gctx, queryMapNames[i])); err != nil {
+			return err
 		}
-		slice := reflect.MakeSlice(argInfo.argType, len(data), len(data))
-		if err := setBasicSlice(slice, argInfo.argType.Elem().Kind(), data); err != nil {
+	}
+
+	//map类型的字段,收集header中带有前缀的值
+	headerPrefixes := a.headerPrefixes
+	for i := range headerPrefixes {
+		filedValue := elemValue.FieldByNameFunc(func(s string) bool {
+			return a.filedNameIsEqual(s, headerPrefixFieldName(headerPrefixes[i]))
+		})
+		if err := a.setMapValue(filedValue, headerPrefixes[i], headerPrefixValues(gctx, headerPrefixes[i])); err != nil {
+			return err
+		}
+	}
+
+	//从gin.Context的key或者context.Context的value上获取
+	contextKeys := a.contextKeys
+	for i := range contextKeys {
+		filedValue := elemValue.FieldByNameFunc(func(s string) bool {
+			return a.filedNameIsEqual(s, contextKeys[i])
+		})
+		value, ok := contextValue(gctx, contextKeys[i])
+		if !ok {
+			return missingContextValue(contextKeys[i], filedValue.Type())
+		}
+		if err := setContextValue(filedValue, contextKeys[i], value); err != nil {
 			return err
 		}
-		argValues = append(argValues, slice)
 	}
+
 	return nil
 }
 
+//pathParam 取出路由上的参数
+func pathParam(gctx *gin.Context, name string) (string, bool) {
+	for _, param := range gctx.Params {
+		if param.Key == name {
+			return param.Value, true
+		}
+	}
+	return "", false
+}
+
+//setSliceValue 给结构体中slice类型的字段赋值
+func (a *argsInfo) setSliceValue(field reflect.Value, name string, data []string) error {
+	if err := a.checkSliceLen(name, len(data)); err != nil {
+		return err
+	}
+	slice := reflect.MakeSlice(field.Type(), len(data), len(data))
+	if err := setBasicSlice(slice, field.Type().Elem().Kind(), data); err != nil {
+		return withField(err, name)
+	}
+	field.Set(slice)
+	return nil
+}
+
+//setMapValue 给结构体中map类型的字段赋值,字段为nil时会先创建
+func (a *argsInfo) setMapValue(field reflect.Value, name string, data map[string][]string) error {
+	if err := a.checkMapLen(name, data); err != nil {
+		return err
+	}
+	if field.IsNil() {
+		field.Set(reflect.MakeMapWithSize(field.Type(), len(data)))
+	}
+	return setBasicMap(field, data)
+}
+
+//queryMapValues 从url以及post的form上收集 name[key]=value 形式的参数,url上的优先
+func (a *argsInfo) queryMapValues(gctx *gin.Context, name string) map[string][]string {
+	data := make(map[string][]string)
+	mergeValues(data, bracketValues(gctx.Request.URL.Query(), name))
+	mergeValues(data, bracketValues(postFormValues(gctx), name))
+	return data
+}
+
+//bracketValues 取出 name[key]=value 形式的参数,返回以key为键的数据
+func bracketValues(form map[string][]string, name string) map[string][]string {
+	data := make(map[string][]string)
+	for k, v := range form {
+		i := strings.IndexByte(k, '[')
+		if i < 1 || k[:i] != name {
+			continue
+		}
+		if j := strings.IndexByte(k[i+1:], ']'); j >= 1 {
+			key := k[i+1:][:j]
+			data[key] = append(data[key], v...)
+		}
+	}
+	return data
+}
+
+//headerPrefixValues 收集header中带有前缀的全部值,key为去掉前缀后的部分
+func headerPrefixValues(gctx *gin.Context, prefix string) map[string][]string {
+	prefix = textproto.CanonicalMIMEHeaderKey(prefix)
+	data := make(map[string][]string)
+	for k, v := range gctx.Request.Header {
+		if len(k) > len(prefix) && strings.HasPrefix(k, prefix) {
+			data[k[len(prefix):]] = v
+		}
+	}
+	return data
+}
+
+//headerValues 取出header的全部值，重复的header以及逗号分隔的列表都会被拆成多个值，
+//开启了headerQValues时会去掉参数，按q值从高到低排序，并丢弃q=0的值
+func (a *argsInfo) headerValues(gctx *gin.Context, name string) []string {
+	result := make([]string, 0)
+	for _, value := range gctx.Request.Header.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	if !a.headerQValues {
+		return result
+	}
+	return sortByQValue(result)
+}
+
+//sortByQValue 解析 text/html;q=0.8 形式的值，按q值从高到低排序，q值相同的保持原有顺序
+func sortByQValue(items []string) []string {
+	type qItem struct {
+		value string
+		q     float64
+	}
+	qItems := make([]qItem, 0, len(items))
+	for i := range items {
+		params := strings.Split(items[i], ";")
+		item := qItem{value: strings.TrimSpace(params[0]), q: 1}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if len(param) > 2 && (param[0] == 'q' || param[0] == 'Q') && param[1] == '=' {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					item.q = q
+				}
+			}
+		}
+		if item.q > 0 {
+			qItems = append(qItems, item)
+		}
+	}
+	sort.SliceStable(qItems, func(i, j int) bool {
+		return qItems[i].q > qItems[j].q
+	})
+	result := make([]string, len(qItems))
+	for i := range qItems {
+		result[i] = qItems[i].value
+	}
+	return result
+}
+
+//cookieValues 取出同名的全部cookie
+func cookieValues(gctx *gin.Context, name string) []string {
+	result := make([]string, 0)
+	for _, cookie := range gctx.Request.Cookies() {
+		if cookie.Name == name {
+			result = append(result, cookie.Value)
+		}
+	}
+	return result
+}
+
+//headerPrefixFieldName header前缀对应到结构体上的字段名,例如 X-Meta- 对应字段 XMeta
+func headerPrefixFieldName(prefix string) string {
+	return strings.ReplaceAll(prefix, "-", "")
+}
+
+//postFormValues 借助gin的form缓存解析post的form,保持与gin的MaxMultipartMemory设置一致
+func postFormValues(gctx *gin.Context) map[string][]string {
+	gctx.GetPostFormArray("")
+	return gctx.Request.PostForm
+}
+
+//mergeValues 合并数据,已经存在的key不会被覆盖
+func mergeValues(dst, src map[string][]string) {
+	for k, v := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = v
+		}
+	}
+}
+
 func defaultArgInfo() argsInfo {
 	return argsInfo{
 		filedNameIsEqual: defaultFieldMatcher,
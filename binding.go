@@ -16,6 +16,7 @@ var rsWriterType = reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()
 var httpRequestType = reflect.TypeOf(&http.Request{})
 var fileHeaderType = reflect.TypeOf(&multipart.FileHeader{})
 var multiFileType = reflect.TypeOf(&multipart.Form{})
+var ginContextType = reflect.TypeOf(&gin.Context{})
 
 // errorType error 的反射类型
 var errorType = reflect.TypeOf((*error)(nil)).Elem()
@@ -29,11 +30,32 @@ type callFunc struct {
 
 	callFnType  reflect.Type
 	callFnValue reflect.Value
+
+	//hasWriter 参数中有http.ResponseWriter
+	hasWriter bool
+	//txInfo 通过WithTransaction设置的事务
+	txInfo *transaction
+	//contextValues 通过WithContextValue设置的参数类型对应的key
+	contextValues map[reflect.Type]interface{}
+	//roles 以及 scopes 调用函数前检查当前用户的权限
+	roles  []string
+	scopes []string
+	//signature 通过WithHMACSignature设置的签名校验
+	signature *signatureConfig
+	//cacheBody 绑定参数之前先缓存body，有[]byte参数或者设置了WithCacheBody时需要
+	cacheBody bool
+	//language 通过WithLanguage设置的默认语言
+	language string
 }
 
 type CallOption func(c *callFunc)
 
 func BindingAndInvoke(invokeFunc interface{}, ops ...CallOption) gin.HandlerFunc {
+	return newCallFunc(invokeFunc, ops...).handlerFunc
+}
+
+//newCallFunc 检查函数的参数以及返回值，生成调用信息
+func newCallFunc(invokeFunc interface{}, ops ...CallOption) *callFunc {
 	c := &callFunc{
 		asInfo: defaultArgInfo(),
 	}
@@ -50,65 +72,99 @@ func BindingAndInvoke(invokeFunc interface{}, ops ...CallOption) gin.HandlerFunc
 		log.Panicf("arg invokeFunc expect a func bug get %s", invokeFuncType.String())
 	}
 
+	if (len(c.roles) > 0 || len(c.scopes) > 0) && principal == nil {
+		log.Panic("WithRoles or WithScopes must RegisterPrincipal first")
+	}
 	checkFuncArg(c, invokeFuncType)
 	checkFuncReturn(c, invokeFuncType)
-	return c.handlerFunc
+	return c
 }
 
 func (c *callFunc) handlerFunc(gctx *gin.Context) {
-	argsNum := len(c.asInfo.args)
-	argValues := make([]reflect.Value, 0, argsNum)
-	first := c.asInfo.args[0]
-	var firstValue reflect.Value
-	if first.IsHttpRequest() {
-		firstValue = reflect.ValueOf(gctx.Request)
-	} else {
-		firstValue = reflect.ValueOf(gctx.Request.Context())
+	if c.language != "" {
+		gctx.Set(languageKey, c.language)
+	}
+	result, err := c.invoke(gctx)
+	if err != nil {
+		c.rsInfo.Return(gctx, nil, err)
+		return
+	}
+	//用户已经绑定了Writer或者已经写入了数据的情况下，没有返回数据代表了，用户已经自定义返回数据了
+	if !c.rsInfo.hasData && (c.hasWriter || gctx.Writer.Written()) {
+		gctx.Next()
+		return
+	}
+	var data interface{}
+	if c.rsInfo.hasData {
+		data = result[0].Interface()
 	}
-	argValues = append(argValues, firstValue)
-	var second *argTypeInfo
+	c.rsInfo.Return(gctx, data, nil)
+}
 
-	if argsNum == 2 {
-		second = c.asInfo.args[1]
-		if second.IsResponseWriter() { //当两个参数是 要么是http.ResponseWriter 要么是需要绑定参数
-			argValues = append(argValues, reflect.ValueOf(gctx.Writer.(http.ResponseWriter)))
-		} else if err := c.asInfo.binding(gctx, second, argValues); err != nil {
-			c.rsInfo.Return(gctx, nil, err)
-			return
+//invoke 校验权限，绑定参数并调用函数，返回函数的结果，绑定失败或者函数返回的错误都通过error返回
+func (c *callFunc) invoke(gctx *gin.Context) ([]reflect.Value, error) {
+	if err := c.authorize(gctx); err != nil {
+		return nil, err
+	}
+	if err := c.asInfo.checkLimits(gctx); err != nil {
+		return nil, err
+	}
+	//签名校验需要在绑定之前读取完整的body
+	if c.signature != nil {
+		if err := c.signature.verify(gctx, c.asInfo.maxBodyBytes); err != nil {
+			return nil, err
 		}
 	}
-
-	if argsNum == 3 { //三个参数必定是第二个参数是http.ResponseWriter，第三个参数是要绑定的参数
-		argValues = append(argValues, reflect.ValueOf(gctx.Writer.(http.ResponseWriter)))
-		if err := c.asInfo.binding(gctx, c.asInfo.args[2], argValues); err != nil {
-			c.rsInfo.Return(gctx, nil, err)
-			return
+	if c.cacheBody {
+		if _, err := cacheBody(gctx, c.asInfo.maxBodyBytes); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.asInfo.parseMultipartForm(gctx); err != nil {
+		return nil, bodyLimitError(gctx, err)
+	}
+	//设置了WithJWT时，即使没有使用claims也需要校验
+	if c.asInfo.jwt != nil {
+		if _, err := c.asInfo.jwt.claims(gctx); err != nil {
+			return nil, err
+		}
+	}
+	argValues := make([]reflect.Value, 0, len(c.asInfo.args))
+	for i := range c.asInfo.args {
+		//事务在所有参数绑定成功后再开启
+		if c.asInfo.args[i].IsTx() {
+			argValues = append(argValues, reflect.Value{})
+			continue
+		}
+		value, err := c.asInfo.argValue(gctx, c.asInfo.args[i])
+		if err != nil {
+			c.asInfo.deleteStored(gctx)
+			return nil, bodyLimitError(gctx, err)
 		}
+		argValues = append(argValues, value)
 	}
 
-	result := c.callFnValue.Call(argValues)
+	var result []reflect.Value
+	if c.txInfo != nil {
+		var err error
+		if result, err = c.invokeInTx(gctx, argValues); err != nil {
+			return nil, err
+		}
+	} else {
+		result = c.callFnValue.Call(argValues)
+	}
 	/*//对于函数调用的结果不做任何处理
 	if c.rsInfo.skipGlobalResponse {
 		gctx.Next()
 		return
 	}*/
 
-	errValue := result[0]
+	errValue := result[len(result)-1]
 	//产生错误的情况下，统一返回。
 	if !errValue.IsNil() {
-		c.rsInfo.Return(gctx, nil, errValue.Interface().(error))
-		return
-	}
-	//用户已经绑定了Writer的情况下，返回数据，就用全局Response，没有返回数据代表了，用户已经自定义返回数据了
-	if second != nil && second.IsResponseWriter() && !c.rsInfo.hasData {
-		gctx.Next()
-		return
-	}
-	var data interface{}
-	if c.rsInfo.hasData {
-		data = result[1].Interface()
+		return nil, errValue.Interface().(error)
 	}
-	c.rsInfo.Return(gctx, data, nil)
+	return result, nil
 }
 
 func checkFuncReturn(c *callFunc, funcType reflect.Type) {
@@ -117,15 +173,15 @@ func checkFuncReturn(c *callFunc, funcType reflect.Type) {
 		log.Panicf("func return arg must err or (anyData,error)")
 	}
 	if out == 1 {
-		in := funcType.In(0)
+		in := funcType.Out(0)
 		if in != errorType {
 			log.Panicf("invokeFunc return on arg must error")
 		}
 	}
 
 	if out == 2 {
-		in1 := funcType.In(0)
-		in2 := funcType.In(1)
+		in1 := funcType.Out(0)
+		in2 := funcType.Out(1)
 		if in1 == errorType || in2 != errorType {
 			log.Panicf("func return arg must (anyData,error) on two arg return")
 		}
@@ -133,52 +189,86 @@ func checkFuncReturn(c *callFunc, funcType reflect.Type) {
 	}
 }
 
+//checkFuncArg 参数的顺序和数量不限，按照类型注入或者绑定，
+//basic,slice,map参数只能有一个，body没有缓存时需要从body上绑定的结构体只能有一个
 func checkFuncArg(c *callFunc, invokeFuncType reflect.Type) {
 	numIn := invokeFuncType.NumIn()
+	//通过WithCacheBody设置时，io.Reader也可以和其他body参数一起使用
+	cacheOption := c.cacheBody
 	argTypes := make([]reflect.Type, 0, numIn)
+	var (
+		namedArg   *argTypeInfo
+		bodyArgs   []*argTypeInfo
+		primaryArg *argTypeInfo
+		readerArg  *argTypeInfo
+	)
+	hasTx := false
 	for i := 0; i < numIn; i++ {
 		argTypes = append(argTypes, invokeFuncType.In(i))
+		//事务的类型可能是结构体指针或者接口，需要在toArgTypeEnum之前判断
+		if c.txInfo != nil && argTypes[i] == c.txInfo.txType {
+			c.asInfo.args = append(c.asInfo.args, &argTypeInfo{argType: argTypes[i], argTypeEnum: txArg})
+			hasTx = true
+			continue
+		}
+		if c.asInfo.jwt != nil && c.asInfo.jwt.isClaims(argTypes[i]) {
+			c.asInfo.args = append(c.asInfo.args, &argTypeInfo{argType: argTypes[i], argTypeEnum: claimsArg})
+			continue
+		}
+		if key, ok := c.contextValues[argTypes[i]]; ok {
+			c.asInfo.args = append(c.asInfo.args, &argTypeInfo{argType: argTypes[i], argTypeEnum: ctxValueArg, contextKey: key})
+			continue
+		}
+		arg := toArgTypeEnum(argTypes[i])
+		switch {
+		case arg.IsResponseWriter():
+			c.hasWriter = true
+		case arg.IsNamedValue():
+			if namedArg != nil {
+				log.Panicf("expect only one of []basicType|map[string]basicType|basicType arg, but get %s", toJoinName(argTypes))
+			}
+			namedArg = arg
+		case arg.argTypeEnum == bodyReaderArg, arg.argTypeEnum == uploadStreamArg:
+			readerArg = arg
+			c.asInfo.streaming = c.asInfo.streaming || arg.argTypeEnum == uploadStreamArg
+		case arg.argTypeEnum == rawBodyArg:
+			//body会被缓存，结构体在后面绑定时也可以继续读取
+			c.cacheBody = true
+		case arg.IsCustomizeStructBind():
+			if arg.bindBody {
+				bodyArgs = append(bodyArgs, arg)
+			}
+			if arg.HasStreamFields() {
+				c.asInfo.streaming = true
+			}
+			if primaryArg == nil {
+				primaryArg = arg
+			}
+		}
+		c.asInfo.args = append(c.asInfo.args, arg)
 	}
 
-	if numIn == 0 || numIn > 3 {
-		log.Panicf("expect func args --->  *http.Request|context.Context [http.ResponseWriter] Struct{}|*Struct{}|[]basicType|basicType , but get %s", toJoinName(argTypes))
+	if len(bodyArgs) > 1 && !c.cacheBody && c.signature == nil {
+		log.Panicf("expect only one struct arg bind from body, other struct arg fields must all with uri or header tag, but get %s", toJoinName(argTypes))
 	}
-
-	first := toArgTypeEnum(argTypes[0])
-	if !first.ValidFirstArgType() {
-		log.Panicf("expect invoke func first arg %s or %s but get %s", httpRequestType.String(), contextType.String(), first.String())
+	if readerArg != nil && !cacheOption && (len(bodyArgs) > 0 || c.cacheBody || c.signature != nil) {
+		log.Panicf("io.Reader and *UploadStream arg read the body directly, can't use with other body arg or WithHMACSignature, but get %s", toJoinName(argTypes))
 	}
-	c.asInfo.args = append(c.asInfo.args, first)
-
-	//参数只有一个情况下，就不需要绑定参数了
-	if numIn == 1 {
-		return
+	if c.txInfo != nil && !hasTx {
+		log.Panicf("WithTransaction expect func arg %s, but get %s", c.txInfo.txType.String(), toJoinName(argTypes))
 	}
 
-	if numIn == 2 {
-		secondArgType := argTypes[1]
-		second := toArgTypeEnum(secondArgType)
-		if !second.ValidSecondArgType() {
-			log.Panicf("second arg must one of (%s),but get %s", "[http.ResponseWriter|Struct{}|*Struct{}|[]basicType|basicType]", second.argTypeEnum)
-		}
-		if !second.IsResponseWriter() {
-			c.asInfo.checkBindValue(second)
-		}
-		c.asInfo.args = append(c.asInfo.args, second)
+	//通过名称设置的字段优先绑定到需要从body上绑定的结构体上
+	if len(bodyArgs) > 0 {
+		primaryArg = bodyArgs[0]
 	}
-
-	if numIn == 3 {
-		second := toArgTypeEnum(argTypes[1])
-		if !second.IsResponseWriter() {
-			log.Panicf("second arg must http.ResponseWriter on three arg")
-		}
-		c.asInfo.args = append(c.asInfo.args, second)
-		three := toArgTypeEnum(argTypes[2])
-		if !three.ValidSecondArgType() {
-			log.Panicf("three arg must one of (%s),but get %s", "[Struct{}|*Struct{}|[]basicType|basicType]", three.argTypeEnum)
+	if primaryArg != nil {
+		primaryArg.primary = true
+	}
+	for i := range c.asInfo.args {
+		if c.asInfo.args[i].IsBindValue() {
+			c.asInfo.checkBindValue(c.asInfo.args[i])
 		}
-		c.asInfo.checkBindValue(three)
-		c.asInfo.args = append(c.asInfo.args, three)
 	}
 }
 
@@ -190,6 +280,14 @@ func toJoinName(allType []reflect.Type) string {
 	return builder.String()
 }
 
+//WithCacheBody 绑定参数之前先读取并缓存完整的body，多个结构体、[]byte以及io.Reader参数都可以读取同一个body，
+//限制和WithMaxBodyBytes一致
+func WithCacheBody() CallOption {
+	return func(c *callFunc) {
+		c.cacheBody = true
+	}
+}
+
 //WithFileName 当参数中是绑定一个文件时使用
 func WithFileName(fileName string) CallOption {
 	return func(c *callFunc) {
@@ -197,7 +295,8 @@ func WithFileName(fileName string) CallOption {
 	}
 }
 
-//WithHeaderNames 当超过2个时，必须在结构体中获取到对应的名称的字段，一个时可以通过在header上获取到数据自动绑定
+//WithHeaderNames 当超过2个时，必须在结构体中获取到对应的名称的字段，一个时可以通过在header上获取到数据自动绑定，
+//绑定到slice上时会取header的全部值，逗号分隔的列表也会被拆开
 func WithHeaderNames(headerNames ...string) CallOption {
 	return func(c *callFunc) {
 		c.asInfo.headerNames = headerNames
@@ -211,7 +310,8 @@ func WithPathNames(pathNames ...string) CallOption {
 	}
 }
 
-//WithCookieNames 当超过2个时，必须在结构体中获取到对应的名称的字段，一个时可以通过在cookie上获取到数据自动绑定
+//WithCookieNames 当超过2个时，必须在结构体中获取到对应的名称的字段，一个时可以通过在cookie上获取到数据自动绑定，
+//绑定到slice上时会取同名的全部cookie
 func WithCookieNames(cookieNames ...string) CallOption {
 	return func(c *callFunc) {
 		c.asInfo.cookieNames = cookieNames
@@ -224,3 +324,76 @@ func WithQueryName(queryName string) CallOption {
 		c.asInfo.queryName = queryName
 	}
 }
+
+//WithQueryMapNames 以 name[key]=value 的形式从url或者post的form上收集map，绑定map参数时只使用第一个，绑定结构体时必须有对应名称的map字段
+func WithQueryMapNames(queryMapNames ...string) CallOption {
+	return func(c *callFunc) {
+		c.asInfo.queryMapNames = queryMapNames
+	}
+}
+
+//WithHeaderPrefixes 收集header中带有该前缀的全部值到map中，key为去掉前缀后的部分，绑定map参数时只使用第一个，
+//绑定结构体时字段名为去掉'-'后的前缀，例如 X-Meta- 对应字段 XMeta
+func WithHeaderPrefixes(headerPrefixes ...string) CallOption {
+	return func(c *callFunc) {
+		c.asInfo.headerPrefixes = headerPrefixes
+	}
+}
+
+//WithQueryStyle 设置url上参数的序列化方式，参照OpenAPI。queryNames 为url上参数的名称，不设置时对所有slice生效，
+//结构体字段也可以通过tag设置，例如 `form:"ids" style:"form" explode:"false"`
+func WithQueryStyle(style QueryStyle, explode bool, queryNames ...string) CallOption {
+	checkQueryStyle(style)
+	return func(c *callFunc) {
+		if c.asInfo.queryStyles == nil {
+			c.asInfo.queryStyles = make(map[string]queryStyle)
+		}
+		if len(queryNames) == 0 {
+			c.asInfo.queryStyles[""] = queryStyle{style: style, explode: explode}
+		}
+		for i := range queryNames {
+			c.asInfo.queryStyles[queryNames[i]] = queryStyle{style: style, explode: explode}
+		}
+	}
+}
+
+//WithHeaderQValues 绑定header到slice上时按照Accept的规则解析q值，去掉参数后按q值从高到低排序，q=0的值会被丢弃
+func WithHeaderQValues() CallOption {
+	return func(c *callFunc) {
+		c.asInfo.headerQValues = true
+	}
+}
+
+//WithSources 设置绑定basic参数时的查找顺序，只会从设置了的来源上获取，每个来源都需要设置对应的名称
+func WithSources(sources ...Source) CallOption {
+	return func(c *callFunc) {
+		c.asInfo.sources = append([]Source{}, sources...)
+	}
+}
+
+//WithOnlySource 只从一个来源上获取basic参数，适用于租户ID这种不允许被其他来源覆盖的参数
+func WithOnlySource(source Source) CallOption {
+	return WithSources(source)
+}
+
+//WithStrictSources 绑定basic参数时检查所有来源，同一个参数在多个来源上的值不一致时返回错误
+func WithStrictSources() CallOption {
+	return func(c *callFunc) {
+		c.asInfo.strictSources = true
+	}
+}
+
+//WithContextKeys 从gin.Context的key或者context.Context的value上获取，绑定到结构体上名称对应的字段，值的类型必须和字段一致，
+//一个时也可以通过WithSources(Context)绑定basic参数。结构体字段也可以通过tag设置，例如 `ctx:"user"`，`ctx:"user,omitempty"`
+func WithContextKeys(contextKeys ...string) CallOption {
+	return func(c *callFunc) {
+		c.asInfo.contextKeys = contextKeys
+	}
+}
+
+//WithMaxBodyBytes 读取body时的限制，Content-Length或者实际读取超过限制时返回413的StatusError
+func WithMaxBodyBytes(maxBodyBytes int64) CallOption {
+	return func(c *callFunc) {
+		c.asInfo.maxBodyBytes = maxBodyBytes
+	}
+}
@@ -0,0 +1,1125 @@
+package gbinding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/assert/v2"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+//serve 注册handler并发起一次请求
+func serve(method, path string, handler gin.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.Handle(method, path, handler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestBindingAndInvoke_map(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+
+	t.Run("all query", func(t *testing.T) {
+		var got map[string][]string
+		handler := BindingAndInvoke(func(ctx context.Context, m map[string][]string) error {
+			got = m
+			return nil
+		})
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?a=1&a=2&b=3", nil))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, map[string][]string{"a": {"1", "2"}, "b": {"3"}})
+	})
+
+	t.Run("bracket form", func(t *testing.T) {
+		var got map[string]string
+		handler := BindingAndInvoke(func(ctx context.Context, m map[string]string) error {
+			got = m
+			return nil
+		}, WithQueryMapNames("attrs"))
+		form := url.Values{"attrs[color]": {"red"}, "attrs[size]": {"xl"}, "other": {"x"}}
+		req := httptest.NewRequest(http.MethodPost, "/?attrs[color]=blue", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		serve(http.MethodPost, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, map[string]string{"color": "blue", "size": "xl"})
+	})
+
+	t.Run("struct fields", func(t *testing.T) {
+		type search struct {
+			Filter map[string]int
+			XMeta  map[string]string
+		}
+		var got search
+		handler := BindingAndInvoke(func(ctx context.Context, s *search) error {
+			got = *s
+			return nil
+		}, WithQueryMapNames("filter"), WithHeaderPrefixes("X-Meta-"))
+		req := httptest.NewRequest(http.MethodGet, "/?filter[age]=18", nil)
+		req.Header.Set("x-meta-trace", "abc")
+		req.Header.Set("X-Other", "1")
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got.Filter, map[string]int{"age": 18})
+		assert.Equal(t, got.XMeta, map[string]string{"Trace": "abc"})
+	})
+
+	t.Run("cast error", func(t *testing.T) {
+		handler := BindingAndInvoke(func(ctx context.Context, m map[string]int) error {
+			return nil
+		})
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?a=x", nil))
+		assert.NotEqual(t, lastErr, nil)
+	})
+}
+
+func TestBindingAndInvoke_queryStyle(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+
+	t.Run("slice arg", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			style   QueryStyle
+			explode bool
+			query   string
+		}{
+			{"form", FormStyle, false, "ids=1,2,3"},
+			{"form explode", FormStyle, true, "ids=1&ids=2&ids=3"},
+			{"spaceDelimited", SpaceDelimitedStyle, false, "ids=1%202%203"},
+			{"pipeDelimited", PipeDelimitedStyle, false, "ids=1|2|3"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var got []int
+				handler := BindingAndInvoke(func(ctx context.Context, ids []int) error {
+					got = ids
+					return nil
+				}, WithQueryName("ids"), WithQueryStyle(tt.style, tt.explode))
+				serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil))
+				assert.Equal(t, lastErr, nil)
+				assert.Equal(t, got, []int{1, 2, 3})
+			})
+		}
+	})
+
+	t.Run("struct fields", func(t *testing.T) {
+		type filter struct {
+			Status string
+			Owner  []string
+		}
+		type search struct {
+			IDs    []int    `form:"ids" style:"form" explode:"false"`
+			Tags   []string `form:"tags"`
+			Filter filter   `form:"filter" style:"deepObject"`
+			Name   string   `form:"name"`
+		}
+		var got search
+		handler := BindingAndInvoke(func(ctx context.Context, s search) error {
+			got = s
+			return nil
+		}, WithQueryStyle(PipeDelimitedStyle, false, "tags"))
+		query := "/?ids=1,2&tags=a|b&name=x,y&status=ignored&filter[status]=open&filter[owner]=me&filter[owner]=you"
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, query, nil))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, search{
+			IDs:    []int{1, 2},
+			Tags:   []string{"a", "b"},
+			Filter: filter{Status: "open", Owner: []string{"me", "you"}},
+			Name:   "x,y",
+		})
+	})
+
+	//每个结构体使用自己的style
+	t.Run("multiple structs", func(t *testing.T) {
+		type paging struct {
+			Page int `form:"page"`
+		}
+		type filter struct {
+			Status string
+		}
+		type search struct {
+			Name   string `form:"name"`
+			Filter filter `form:"filter" style:"deepObject"`
+			IDs    []int  `form:"ids" style:"pipeDelimited"`
+		}
+		var (
+			gotPaging paging
+			gotSearch search
+		)
+		handler := BindingAndInvoke(func(s search, p paging) error {
+			gotPaging, gotSearch = p, s
+			return nil
+		}, WithCacheBody())
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?page=2&name=x&ids=1|2&filter[status]=open", nil))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, gotPaging, paging{Page: 2})
+		assert.Equal(t, gotSearch, search{Name: "x", Filter: filter{Status: "open"}, IDs: []int{1, 2}})
+	})
+
+	t.Run("invalid field", func(t *testing.T) {
+		type search struct {
+			Name string `style:"pipeDelimited"`
+		}
+		defer func() {
+			assert.Equal(t, recover().(string), "struct:gbinding.search field:Name pipeDelimited style expect slice but get string")
+		}()
+		BindingAndInvoke(func(ctx context.Context, s search) error {
+			return nil
+		})
+	})
+}
+
+func TestBindingAndInvoke_multiValueHeaderCookie(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+
+	t.Run("header slice arg", func(t *testing.T) {
+		var got []string
+		handler := BindingAndInvoke(func(ctx context.Context, values []string) error {
+			got = values
+			return nil
+		}, WithHeaderNames("X-Tag"))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Add("X-Tag", "a, b")
+		req.Header.Add("X-Tag", "c")
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, []string{"a", "b", "c"})
+	})
+
+	t.Run("q values", func(t *testing.T) {
+		var got []string
+		handler := BindingAndInvoke(func(ctx context.Context, values []string) error {
+			got = values
+			return nil
+		}, WithHeaderNames("Accept-Language"), WithHeaderQValues())
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", "en;q=0.8, zh-CN, fr;q=0, de;q=0.8")
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, []string{"zh-CN", "en", "de"})
+	})
+
+	t.Run("cookie slice arg", func(t *testing.T) {
+		var got []int
+		handler := BindingAndInvoke(func(ctx context.Context, values []int) error {
+			got = values
+			return nil
+		}, WithCookieNames("id"))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Cookie", "id=1; other=x; id=2")
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, []int{1, 2})
+	})
+
+	t.Run("struct fields", func(t *testing.T) {
+		type request struct {
+			Accept  []string
+			Session []string
+			Limit   int
+		}
+		var got request
+		handler := BindingAndInvoke(func(ctx context.Context, r *request) error {
+			got = *r
+			return nil
+		}, WithHeaderNames("Accept", "Limit"), WithCookieNames("session"))
+		//没有该header时为零值，url上的同名参数不会生效
+		req := httptest.NewRequest(http.MethodGet, "/?Limit=5", nil)
+		req.Header.Set("Accept", "text/html, application/json")
+		req.Header.Set("Cookie", "session=a; session=b")
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, request{
+			Accept:  []string{"text/html", "application/json"},
+			Session: []string{"a", "b"},
+		})
+	})
+}
+
+func TestBindingAndInvoke_sources(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+	newReq := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/t1?tenant=q", nil)
+		req.Header.Set("X-Tenant", "h")
+		return req
+	}
+
+	t.Run("default order", func(t *testing.T) {
+		var got string
+		handler := BindingAndInvoke(func(ctx context.Context, tenant string) error {
+			got = tenant
+			return nil
+		}, WithQueryName("tenant"), WithPathNames("tenant"), WithHeaderNames("X-Tenant"))
+		serve(http.MethodGet, "/:tenant", handler, newReq())
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, "q")
+	})
+
+	t.Run("custom order", func(t *testing.T) {
+		var got string
+		handler := BindingAndInvoke(func(ctx context.Context, tenant string) error {
+			got = tenant
+			return nil
+		}, WithQueryName("tenant"), WithPathNames("tenant"), WithHeaderNames("X-Tenant"), WithSources(Path, Header, Query))
+		serve(http.MethodGet, "/:tenant", handler, newReq())
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, "t1")
+	})
+
+	t.Run("only source", func(t *testing.T) {
+		var got string
+		handler := BindingAndInvoke(func(ctx context.Context, tenant string) error {
+			got = tenant
+			return nil
+		}, WithQueryName("X-Tenant"), WithHeaderNames("X-Tenant"), WithOnlySource(Header))
+		req := httptest.NewRequest(http.MethodGet, "/?X-Tenant=q", nil)
+		serve(http.MethodGet, "/", handler, req)
+		assert.NotEqual(t, lastErr, nil)
+		req.Header.Set("X-Tenant", "h")
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, "h")
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		handler := BindingAndInvoke(func(ctx context.Context, tenant string) error {
+			return nil
+		}, WithQueryName("tenant"), WithHeaderNames("X-Tenant"), WithStrictSources())
+		serve(http.MethodGet, "/:tenant", handler, newReq())
+		assert.Equal(t, lastErr.Error(), `Bad Request: param conflict, query get "q" but header get "h"`)
+		var bindErr *BindError
+		assert.Equal(t, errors.As(lastErr, &bindErr), true)
+		assert.Equal(t, bindErr.Kind, KindConflict)
+	})
+
+	t.Run("source without name", func(t *testing.T) {
+		defer func() {
+			assert.Equal(t, recover().(string), "source cookie has no name, please check you option name set")
+		}()
+		BindingAndInvoke(func(ctx context.Context, tenant string) error {
+			return nil
+		}, WithQueryName("tenant"), WithSources(Query, Cookie))
+	})
+}
+
+func TestBindingAndInvoke_args(t *testing.T) {
+	var lastErr error
+	var lastData interface{}
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastData, lastErr = data, err
+	})
+
+	type pathParams struct {
+		ID      int    `uri:"id" binding:"required"`
+		TraceID string `header:"X-Trace-Id"`
+	}
+	type body struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	t.Run("any order", func(t *testing.T) {
+		handler := BindingAndInvoke(func(b body, gctx *gin.Context, p *pathParams, req *http.Request, ctx context.Context) (string, error) {
+			return fmt.Sprintf("%d %s %s %s %v", p.ID, p.TraceID, b.Name, req.Method, gctx.Request.Context() == ctx), nil
+		})
+		req := httptest.NewRequest(http.MethodPut, "/users/7", strings.NewReader(`{"name":"tom"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Trace-Id", "t1")
+		serve(http.MethodPut, "/users/:id", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, "7 t1 tom PUT true")
+	})
+
+	//uri以及header tag的字段不会被url以及body上的同名参数覆盖
+	t.Run("param fields win", func(t *testing.T) {
+		type request struct {
+			ID      int    `uri:"id"`
+			TraceID string `header:"X-Trace-Id"`
+			Name    string `form:"name" json:"name"`
+		}
+		handler := BindingAndInvoke(func(r request) (string, error) {
+			return fmt.Sprintf("%d %q %s", r.ID, r.TraceID, r.Name), nil
+		})
+		serve(http.MethodGet, "/users/:id", handler, httptest.NewRequest(http.MethodGet, "/users/5?ID=7&TraceID=evil&name=tom", nil))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, `5 "" tom`)
+
+		req := httptest.NewRequest(http.MethodPut, "/users/5", strings.NewReader(`{"ID":7,"TraceID":"evil","name":"tom"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Trace-Id", "t1")
+		serve(http.MethodPut, "/users/:id", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, `5 "t1" tom`)
+	})
+
+	t.Run("no arg", func(t *testing.T) {
+		handler := BindingAndInvoke(func() (string, error) {
+			return "ok", nil
+		})
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, "ok")
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		lastData = nil
+		handler := BindingAndInvoke(func(w http.ResponseWriter, id int) error {
+			_, err := w.Write([]byte(fmt.Sprint(id)))
+			return err
+		}, WithQueryName("id"))
+		w := serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?id=3", nil))
+		assert.Equal(t, lastData, nil)
+		assert.Equal(t, w.Body.String(), "3")
+	})
+
+	t.Run("param struct validate", func(t *testing.T) {
+		handler := BindingAndInvoke(func(p pathParams) error {
+			return nil
+		})
+		serve(http.MethodGet, "/users", handler, httptest.NewRequest(http.MethodGet, "/users", nil))
+		assert.NotEqual(t, lastErr, nil)
+	})
+
+	t.Run("two body struct", func(t *testing.T) {
+		defer func() {
+			assert.Equal(t, recover().(string), "expect only one struct arg bind from body, other struct arg fields must all with uri or header tag, but get gbinding.body *gbinding.body ")
+		}()
+		BindingAndInvoke(func(a body, b *body) error {
+			return nil
+		})
+	})
+
+	t.Run("two named value", func(t *testing.T) {
+		defer func() {
+			assert.Equal(t, recover().(string), "expect only one of []basicType|map[string]basicType|basicType arg, but get int []int ")
+		}()
+		BindingAndInvoke(func(a int, b []int) error {
+			return nil
+		}, WithQueryName("id"))
+	})
+}
+
+type testClock interface {
+	Now() string
+}
+
+type fixedClock string
+
+func (f fixedClock) Now() string {
+	return string(f)
+}
+
+type testRepo struct {
+	name string
+}
+
+func TestBindingAndInvoke_inject(t *testing.T) {
+	var lastErr error
+	var lastData interface{}
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastData, lastErr = data, err
+	})
+	ProvideValue[testClock](fixedClock("noon"))
+	Provide(func(gctx *gin.Context) (*testRepo, error) {
+		name := gctx.GetHeader("X-Repo")
+		if name == "" {
+			return nil, errors.New("no repo")
+		}
+		return &testRepo{name: name}, nil
+	})
+	defer func() {
+		delete(providers, reflect.TypeOf((*testClock)(nil)).Elem())
+		delete(providers, reflect.TypeOf(&testRepo{}))
+	}()
+
+	handler := BindingAndInvoke(func(ctx context.Context, clock testClock, repo *testRepo, id int) (string, error) {
+		return fmt.Sprintf("%s %s %d", clock.Now(), repo.name, id), nil
+	}, WithQueryName("id"))
+
+	req := httptest.NewRequest(http.MethodGet, "/?id=1", nil)
+	req.Header.Set("X-Repo", "users")
+	serve(http.MethodGet, "/", handler, req)
+	assert.Equal(t, lastErr, nil)
+	assert.Equal(t, lastData, "noon users 1")
+
+	serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?id=1", nil))
+	assert.Equal(t, lastErr.Error(), "no repo")
+
+	t.Run("builtin", func(t *testing.T) {
+		defer func() {
+			assert.Equal(t, recover().(string), "type context.Context is builtin, can't provide")
+		}()
+		ProvideValue(context.Background())
+	})
+}
+
+type fakeTx struct {
+	committed bool
+	rollback  bool
+	commitErr error
+}
+
+func (f *fakeTx) Commit() error {
+	f.committed = true
+	return f.commitErr
+}
+
+func (f *fakeTx) Rollback() error {
+	f.rollback = true
+	return nil
+}
+
+func TestBindingAndInvoke_transaction(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+	var tx *fakeTx
+	begin := func(gctx *gin.Context) (*fakeTx, error) {
+		tx = &fakeTx{}
+		if gctx.Query("fail") != "" {
+			tx.commitErr = errors.New("commit failed")
+		}
+		return tx, nil
+	}
+	handler := BindingAndInvoke(func(tx *fakeTx, id int) error {
+		if id == 0 {
+			return errors.New("bad id")
+		}
+		if id < 0 {
+			panic("boom")
+		}
+		return nil
+	}, WithQueryName("id"), WithTransaction(begin))
+
+	t.Run("commit", func(t *testing.T) {
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?id=1", nil))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, *tx, fakeTx{committed: true})
+	})
+
+	t.Run("commit error", func(t *testing.T) {
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?id=1&fail=1", nil))
+		assert.Equal(t, lastErr.Error(), "commit failed")
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?id=0", nil))
+		assert.Equal(t, lastErr.Error(), "bad id")
+		assert.Equal(t, *tx, fakeTx{rollback: true})
+	})
+
+	t.Run("rollback on panic", func(t *testing.T) {
+		func() {
+			defer func() {
+				assert.Equal(t, recover(), "boom")
+			}()
+			serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?id=-1", nil))
+		}()
+		assert.Equal(t, *tx, fakeTx{rollback: true})
+	})
+
+	t.Run("no begin on binding error", func(t *testing.T) {
+		tx = nil
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?id=x", nil))
+		assert.NotEqual(t, lastErr, nil)
+		assert.Equal(t, tx, (*fakeTx)(nil))
+	})
+
+	t.Run("missing arg", func(t *testing.T) {
+		defer func() {
+			assert.Equal(t, recover().(string), "WithTransaction expect func arg *gbinding.fakeTx, but get context.Context ")
+		}()
+		BindingAndInvoke(func(ctx context.Context) error {
+			return nil
+		}, WithTransaction(begin))
+	})
+}
+
+type testUser struct {
+	Name string
+}
+
+type testCtxKey struct{}
+
+func TestBindingAndInvoke_contextValue(t *testing.T) {
+	var lastErr error
+	var lastData interface{}
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastData, lastErr = data, err
+	})
+	serveWith := func(handler gin.HandlerFunc, values map[string]interface{}) {
+		r := gin.New()
+		r.POST("/", func(gctx *gin.Context) {
+			for k, v := range values {
+				gctx.Set(k, v)
+			}
+			gctx.Request = gctx.Request.WithContext(context.WithValue(gctx.Request.Context(), testCtxKey{}, "traced"))
+		}, handler)
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"User":{"Name":"hacker"},"title":"t"}`))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	type request struct {
+		User   *testUser `ctx:"user"`
+		Tenant int
+		Role   string `ctx:"role,omitempty"`
+		Title  string `json:"title"`
+	}
+	handler := BindingAndInvoke(func(r request, u *testUser) (string, error) {
+		return fmt.Sprintf("%s %d %q %s %s", r.User.Name, r.Tenant, r.Role, r.Title, u.Name), nil
+	}, WithContextValue[*testUser]("user"), WithContextKeys("tenant"))
+
+	t.Run("bind", func(t *testing.T) {
+		serveWith(handler, map[string]interface{}{"user": &testUser{Name: "tom"}, "tenant": 7})
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, `tom 7 "" t tom`)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		serveWith(handler, map[string]interface{}{"tenant": 7})
+		assert.Equal(t, lastErr.Error(), "Bad Request: context key user not found")
+		var bindErr *BindError
+		assert.Equal(t, errors.As(lastErr, &bindErr), true)
+		assert.Equal(t, bindErr.Kind, KindContextMissing)
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		serveWith(handler, map[string]interface{}{"user": testUser{Name: "tom"}, "tenant": 7})
+		assert.Equal(t, lastErr.Error(), "context key user expect *gbinding.testUser but get gbinding.testUser")
+	})
+
+	t.Run("basic arg", func(t *testing.T) {
+		handler := BindingAndInvoke(func(tenant int) (int, error) {
+			return tenant, nil
+		}, WithQueryName("tenant"), WithContextKeys("tenant"), WithOnlySource(Context))
+		serveWith(handler, map[string]interface{}{"tenant": 7})
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, 7)
+	})
+
+	t.Run("context.Context value", func(t *testing.T) {
+		handler := BindingAndInvoke(func(trace string) (string, error) {
+			return trace, nil
+		}, WithContextValue[string](testCtxKey{}))
+		serveWith(handler, nil)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, "traced")
+	})
+}
+
+type testPrincipal struct {
+	name   string
+	roles  []string
+	scopes []string
+}
+
+func (p *testPrincipal) Roles() []string {
+	return p.roles
+}
+
+func (p *testPrincipal) Scopes() []string {
+	return p.scopes
+}
+
+func TestBindingAndInvoke_principal(t *testing.T) {
+	var lastErr error
+	var lastData interface{}
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastData, lastErr = data, err
+	})
+	calls := 0
+	RegisterPrincipal(func(gctx *gin.Context) (*testPrincipal, error) {
+		calls++
+		switch gctx.GetHeader("X-User") {
+		case "admin":
+			return &testPrincipal{name: "admin", roles: []string{"admin"}, scopes: []string{"read", "write"}}, nil
+		case "guest":
+			return &testPrincipal{name: "guest", roles: []string{"guest"}, scopes: []string{"read"}}, nil
+		}
+		return nil, errors.New("no user")
+	})
+	defer func() {
+		principal = nil
+	}()
+
+	handler := BindingAndInvoke(func(p *testPrincipal) (string, error) {
+		return p.name, nil
+	}, WithRoles("admin", "editor"), WithScopes("read", "write"))
+	request := func(user string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-User", user)
+		return req
+	}
+	statusOf := func(err error) int {
+		var authErr *AuthError
+		if errors.As(err, &authErr) {
+			return authErr.StatusCode
+		}
+		return 0
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		calls = 0
+		serve(http.MethodGet, "/", handler, request("admin"))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, "admin")
+		assert.Equal(t, calls, 1)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		serve(http.MethodGet, "/", handler, request(""))
+		assert.Equal(t, statusOf(lastErr), http.StatusUnauthorized)
+		assert.Equal(t, lastErr.Error(), "Unauthorized: no user")
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		serve(http.MethodGet, "/", handler, request("guest"))
+		assert.Equal(t, statusOf(lastErr), http.StatusForbidden)
+	})
+
+	t.Run("not registered", func(t *testing.T) {
+		p := principal
+		principal = nil
+		defer func() {
+			principal = p
+			assert.Equal(t, recover().(string), "WithRoles or WithScopes must RegisterPrincipal first")
+		}()
+		BindingAndInvoke(func() error {
+			return nil
+		}, WithRoles("admin"))
+	})
+}
+
+func TestBindingAndInvoke_credentials(t *testing.T) {
+	var lastErr error
+	var lastData interface{}
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastData, lastErr = data, err
+	})
+
+	t.Run("bearer", func(t *testing.T) {
+		handler := BindingAndInvoke(func(c Credentials) (Credentials, error) {
+			return c, nil
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "bearer abc.def")
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, Credentials{Bearer: "abc.def"})
+	})
+
+	t.Run("basic and api key", func(t *testing.T) {
+		handler := BindingAndInvoke(func(c Credentials) (Credentials, error) {
+			return c, nil
+		}, WithAPIKey(Query, "api_key"))
+		req := httptest.NewRequest(http.MethodGet, "/?api_key=k1", nil)
+		req.SetBasicAuth("tom", "secret")
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, Credentials{Username: "tom", Password: "secret", APIKey: "k1"})
+	})
+
+	t.Run("malformed basic", func(t *testing.T) {
+		handler := BindingAndInvoke(func(c Credentials) error {
+			return nil
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Basic !!!")
+		serve(http.MethodGet, "/", handler, req)
+		var authErr *AuthError
+		assert.Equal(t, errors.As(lastErr, &authErr), true)
+		assert.Equal(t, authErr.StatusCode, http.StatusUnauthorized)
+	})
+
+	t.Run("struct fields", func(t *testing.T) {
+		type login struct {
+			Username string `auth:"username"`
+			Password string `auth:"password"`
+			Key      string `auth:"apikey"`
+		}
+		handler := BindingAndInvoke(func(l login) (login, error) {
+			return l, nil
+		}, WithAPIKey(Header, "X-Api-Key"))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth("tom", "secret")
+		req.Header.Set("X-Api-Key", "k2")
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, login{Username: "tom", Password: "secret", Key: "k2"})
+	})
+
+	//带有auth tag的字段只从Authorization以及api key的位置获取
+	t.Run("struct fields ignore query and body", func(t *testing.T) {
+		type request struct {
+			Token string `auth:"bearer"`
+			Name  string `form:"name" json:"name"`
+		}
+		handler := BindingAndInvoke(func(r request) (request, error) {
+			return r, nil
+		})
+		req := httptest.NewRequest(http.MethodGet, "/?Token=forged&name=tom", nil)
+		req.Header.Set("Authorization", "Bearer real")
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, request{Token: "real", Name: "tom"})
+
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?Token=forged&name=tom", nil))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, request{Name: "tom"})
+
+		req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Token":"forged","name":"tom"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer real")
+		serve(http.MethodPost, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, request{Token: "real", Name: "tom"})
+	})
+
+	t.Run("api key without option", func(t *testing.T) {
+		type request struct {
+			Key string `auth:"apikey"`
+		}
+		defer func() {
+			assert.Equal(t, recover().(string), "struct:gbinding.request field:Key auth apikey must set WithAPIKey")
+		}()
+		BindingAndInvoke(func(r request) error {
+			return nil
+		})
+	})
+}
+
+func TestBindingAndInvoke_body(t *testing.T) {
+	var lastErr error
+	var lastData interface{}
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastData, lastErr = data, err
+	})
+	newReq := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	t.Run("bytes and struct", func(t *testing.T) {
+		type event struct {
+			Name string `json:"name"`
+		}
+		handler := BindingAndInvoke(func(e *event, raw []byte, msg json.RawMessage) (string, error) {
+			return e.Name + " " + string(raw) + " " + string(msg), nil
+		})
+		serve(http.MethodPost, "/", handler, newReq(`{"name":"a"}`))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, `a {"name":"a"} {"name":"a"}`)
+	})
+
+	t.Run("reader", func(t *testing.T) {
+		handler := BindingAndInvoke(func(r io.Reader) (string, error) {
+			data, err := ioutil.ReadAll(r)
+			return string(data), err
+		})
+		serve(http.MethodPost, "/", handler, newReq("hello"))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, "hello")
+	})
+
+	t.Run("max body bytes", func(t *testing.T) {
+		handler := BindingAndInvoke(func(raw []byte) error {
+			return nil
+		}, WithMaxBodyBytes(4))
+		serve(http.MethodPost, "/", handler, newReq("hello"))
+		var statusErr *StatusError
+		assert.Equal(t, errors.As(lastErr, &statusErr), true)
+		assert.Equal(t, statusErr.StatusCode, http.StatusRequestEntityTooLarge)
+
+		serve(http.MethodPost, "/", handler, newReq("hell"))
+		assert.Equal(t, lastErr, nil)
+	})
+
+	t.Run("reader limit", func(t *testing.T) {
+		handler := BindingAndInvoke(func(r io.ReadCloser) error {
+			_, err := ioutil.ReadAll(r)
+			return err
+		}, WithMaxBodyBytes(4))
+		serve(http.MethodPost, "/", handler, newReq("hello"))
+		assert.NotEqual(t, lastErr, nil)
+	})
+
+	t.Run("reader with struct", func(t *testing.T) {
+		type event struct {
+			Name string `json:"name"`
+		}
+		defer func() {
+			assert.Equal(t, recover().(string), "io.Reader and *UploadStream arg read the body directly, can't use with other body arg or WithHMACSignature, but get io.Reader gbinding.event ")
+		}()
+		BindingAndInvoke(func(r io.Reader, e event) error {
+			return nil
+		})
+	})
+}
+
+func TestBindingAndInvoke_cacheBody(t *testing.T) {
+	var lastErr error
+	var lastData interface{}
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastData, lastErr = data, err
+	})
+	newReq := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+	type event struct {
+		Name string `json:"name"`
+	}
+	type audit struct {
+		ID int `json:"id"`
+	}
+
+	t.Run("multiple struct", func(t *testing.T) {
+		handler := BindingAndInvoke(func(e event, a *audit, r io.Reader) (string, error) {
+			data, err := ioutil.ReadAll(r)
+			return fmt.Sprintf("%s %d %s", e.Name, a.ID, data), err
+		}, WithCacheBody())
+		serve(http.MethodPost, "/", handler, newReq(`{"name":"a","id":1}`))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, `a 1 {"name":"a","id":1}`)
+	})
+
+	t.Run("middleware", func(t *testing.T) {
+		var fromMiddleware event
+		r := gin.New()
+		r.Use(CacheBody(64), func(gctx *gin.Context) {
+			assert.Equal(t, gctx.ShouldBindBodyWith(&fromMiddleware, binding.JSON), nil)
+		})
+		r.POST("/", BindingAndInvoke(func(e event) (string, error) {
+			return e.Name, nil
+		}))
+		r.ServeHTTP(httptest.NewRecorder(), newReq(`{"name":"a"}`))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, "a")
+		assert.Equal(t, fromMiddleware.Name, "a")
+
+		lastData = nil
+		r.ServeHTTP(httptest.NewRecorder(), newReq(strings.Repeat("a", 65)))
+		var statusErr *StatusError
+		assert.Equal(t, errors.As(lastErr, &statusErr), true)
+		assert.Equal(t, statusErr.StatusCode, http.StatusRequestEntityTooLarge)
+		assert.Equal(t, lastData, nil)
+	})
+
+	t.Run("provider", func(t *testing.T) {
+		type bodySize int
+		Provide(func(gctx *gin.Context) (bodySize, error) {
+			body, err := CachedBody(gctx)
+			return bodySize(len(body)), err
+		})
+		handler := BindingAndInvoke(func(size bodySize, e event) (string, error) {
+			return fmt.Sprintf("%s %d", e.Name, size), nil
+		})
+		serve(http.MethodPost, "/", handler, newReq(`{"name":"a"}`))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, "a 12")
+	})
+}
+
+func TestBindingAndInvoke_limits(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+	assertStatus := func(t *testing.T, statusCode int, target error) {
+		var statusErr *StatusError
+		assert.Equal(t, errors.As(lastErr, &statusErr), true)
+		assert.Equal(t, statusErr.StatusCode, statusCode)
+		assert.Equal(t, errors.Is(lastErr, target), true)
+	}
+
+	t.Run("query params", func(t *testing.T) {
+		handler := BindingAndInvoke(func(ids []int) error {
+			return nil
+		}, WithQueryName("ids"), WithMaxQueryParams(2))
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?ids=1&ids=2", nil))
+		assert.Equal(t, lastErr, nil)
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?ids=1&ids=2&ids=3", nil))
+		assertStatus(t, http.StatusBadRequest, ErrTooManyQueryParams)
+	})
+
+	t.Run("slice len", func(t *testing.T) {
+		handler := BindingAndInvoke(func(ids []int) error {
+			return nil
+		}, WithQueryName("ids"), WithQueryStyle(FormStyle, false), WithMaxSliceLen(2))
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?ids=1,2,3", nil))
+		assertStatus(t, http.StatusBadRequest, ErrSliceTooLong)
+	})
+
+	t.Run("struct slice len", func(t *testing.T) {
+		type search struct {
+			IDs  []int `form:"ids"`
+			Tags []string
+		}
+		handler := BindingAndInvoke(func(s search) error {
+			return nil
+		}, WithHeaderNames("tags"), WithMaxSliceLen(2))
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?ids=1&ids=2&ids=3", nil))
+		assertStatus(t, http.StatusBadRequest, ErrSliceTooLong)
+
+		req := httptest.NewRequest(http.MethodGet, "/?ids=1", nil)
+		req.Header.Set("Tags", "a,b,c")
+		serve(http.MethodGet, "/", handler, req)
+		assertStatus(t, http.StatusBadRequest, ErrSliceTooLong)
+	})
+
+	t.Run("header value len", func(t *testing.T) {
+		handler := BindingAndInvoke(func(name string) error {
+			return nil
+		}, WithHeaderNames("X-Name"), WithMaxHeaderValueLen(4))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Name", "abcde")
+		serve(http.MethodGet, "/", handler, req)
+		assertStatus(t, http.StatusBadRequest, ErrHeaderTooLong)
+	})
+
+	t.Run("body bytes", func(t *testing.T) {
+		type event struct {
+			Name string `json:"name"`
+		}
+		handler := BindingAndInvoke(func(e event) error {
+			return nil
+		}, WithMaxBodyBytes(8))
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"abcdef"}`))
+		req.Header.Set("Content-Type", "application/json")
+		serve(http.MethodPost, "/", handler, req)
+		assertStatus(t, http.StatusRequestEntityTooLarge, ErrBodyTooLarge)
+
+		//没有Content-Length时读取过程中判断
+		req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"abcdef"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.ContentLength = -1
+		serve(http.MethodPost, "/", handler, req)
+		assertStatus(t, http.StatusRequestEntityTooLarge, ErrBodyTooLarge)
+	})
+
+	t.Run("multipart memory", func(t *testing.T) {
+		body := &strings.Builder{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "a.txt")
+		_, _ = part.Write([]byte(strings.Repeat("a", 64)))
+		_ = writer.WriteField("name", "x")
+		_ = writer.Close()
+
+		var got string
+		handler := BindingAndInvoke(func(file *multipart.FileHeader) error {
+			got = file.Filename
+			return nil
+		}, WithFileName("file"), WithMaxMultipartMemory(16))
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body.String()))
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		serve(http.MethodPost, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, "a.txt")
+	})
+}
+
+func TestBindingAndInvoke_fileFields(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+	type upload struct {
+		Title       string                  `form:"title"`
+		Avatar      *multipart.FileHeader   `form:"avatar"`
+		Attachments []*multipart.FileHeader `form:"attachments"`
+		Images      []*multipart.FileHeader `form:"images"`
+	}
+	newReq := func(files map[string]string) *http.Request {
+		body := &strings.Builder{}
+		writer := multipart.NewWriter(body)
+		_ = writer.WriteField("title", "hello")
+		for _, key := range []string{"avatar", "attachments[]", "images[1]", "images[0]"} {
+			if filename, ok := files[key]; ok {
+				part, _ := writer.CreateFormFile(key, filename)
+				_, _ = part.Write([]byte(filename))
+			}
+		}
+		_ = writer.Close()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body.String()))
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+	filenames := func(files []*multipart.FileHeader) []string {
+		result := make([]string, 0, len(files))
+		for i := range files {
+			result = append(result, files[i].Filename)
+		}
+		return result
+	}
+
+	t.Run("bind", func(t *testing.T) {
+		var got upload
+		handler := BindingAndInvoke(func(u *upload) error {
+			got = *u
+			return nil
+		})
+		serve(http.MethodPost, "/", handler, newReq(map[string]string{
+			"avatar":        "a.png",
+			"attachments[]": "b.pdf",
+			"images[1]":     "d.png",
+			"images[0]":     "c.png",
+		}))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got.Title, "hello")
+		assert.Equal(t, got.Avatar.Filename, "a.png")
+		assert.Equal(t, filenames(got.Attachments), []string{"b.pdf"})
+		assert.Equal(t, filenames(got.Images), []string{"c.png", "d.png"})
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		var got upload
+		handler := BindingAndInvoke(func(u upload) error {
+			got = u
+			return nil
+		})
+		serve(http.MethodPost, "/", handler, newReq(nil))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got.Title, "hello")
+		assert.Equal(t, got.Avatar == nil, true)
+		assert.Equal(t, len(got.Attachments), 0)
+	})
+
+	t.Run("slice len", func(t *testing.T) {
+		handler := BindingAndInvoke(func(u upload) error {
+			return nil
+		}, WithMaxSliceLen(1))
+		serve(http.MethodPost, "/", handler, newReq(map[string]string{"images[1]": "d.png", "images[0]": "c.png"}))
+		assert.Equal(t, errors.Is(lastErr, ErrSliceTooLong), true)
+	})
+}
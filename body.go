@@ -0,0 +1,104 @@
+package gbinding
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+//ErrBodyTooLarge body超过了WithMaxBodyBytes的限制，包装在413的StatusError中
+var ErrBodyTooLarge = errors.New("request body too large")
+
+var bytesType = reflect.TypeOf([]byte{})
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+var readerType = reflect.TypeOf((*io.Reader)(nil)).Elem()
+var readCloserType = reflect.TypeOf((*io.ReadCloser)(nil)).Elem()
+
+//cacheBody 读取完整的body并缓存在gin.Context上，同时重置Request.Body，后面的绑定以及ShouldBindBodyWith都可以继续使用，
+//limit大于0时超过限制返回413的StatusError
+func cacheBody(gctx *gin.Context, limit int64) ([]byte, error) {
+	if cached, ok := gctx.Get(gin.BodyBytesKey); ok {
+		if body, ok := cached.([]byte); ok {
+			if limit > 0 && int64(len(body)) > limit {
+				return nil, &StatusError{StatusCode: http.StatusRequestEntityTooLarge, Err: ErrBodyTooLarge}
+			}
+			gctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+			return body, nil
+		}
+	}
+	if gctx.Request.Body == nil {
+		return nil, nil
+	}
+	reader := io.Reader(gctx.Request.Body)
+	if limit > 0 {
+		//多读一个字节用于判断是否超过限制
+		reader = io.LimitReader(reader, limit+1)
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(body)) > limit {
+		return nil, &StatusError{StatusCode: http.StatusRequestEntityTooLarge, Err: ErrBodyTooLarge}
+	}
+	_ = gctx.Request.Body.Close()
+	gctx.Set(gin.BodyBytesKey, body)
+	gctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+//CacheBody 读取完整的body并缓存，之后的中间件以及handler可以多次读取，包括ShouldBindBodyWith，
+//limit大于0时超过限制返回413的StatusError并中止请求
+func CacheBody(limit int64) gin.HandlerFunc {
+	return func(gctx *gin.Context) {
+		if _, err := cacheBody(gctx, limit); err != nil {
+			gctx.Abort()
+			(&response{}).Return(gctx, nil, err)
+			return
+		}
+		gctx.Next()
+	}
+}
+
+//CachedBody 获取缓存的body，还没有缓存时读取并缓存，可以在Provide的provider以及中间件中使用
+func CachedBody(gctx *gin.Context) ([]byte, error) {
+	return cacheBody(gctx, 0)
+}
+
+//rewindBody body已经缓存时重置Request.Body，保证每次绑定都从头读取
+func rewindBody(gctx *gin.Context) {
+	if cached, ok := gctx.Get(gin.BodyBytesKey); ok {
+		if body, ok := cached.([]byte); ok {
+			gctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+	}
+}
+
+//bindRawBody 绑定[]byte以及json.RawMessage参数
+func (a *argsInfo) bindRawBody(gctx *gin.Context, argInfo *argTypeInfo) (reflect.Value, error) {
+	body, err := cacheBody(gctx, a.maxBodyBytes)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if body == nil {
+		body = []byte{}
+	}
+	return reflect.ValueOf(body).Convert(argInfo.argType), nil
+}
+
+//bindBodyReader 绑定io.Reader以及io.ReadCloser参数，没有设置WithCacheBody时不会缓存body，
+//超过WithMaxBodyBytes的限制时读取会返回413的StatusError
+func (a *argsInfo) bindBodyReader(gctx *gin.Context, argInfo *argTypeInfo) (reflect.Value, error) {
+	rewindBody(gctx)
+	body := gctx.Request.Body
+	if body == nil {
+		body = http.NoBody
+	}
+	return reflect.ValueOf(body).Convert(argInfo.argType), nil
+}
@@ -0,0 +1,94 @@
+package gbinding
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+//contextValue 先从gin.Context的key上取，没有再从context.Context的value上取，值为nil时视为不存在
+func contextValue(gctx *gin.Context, key interface{}) (interface{}, bool) {
+	if name, ok := key.(string); ok {
+		if value, ok := gctx.Get(name); ok && value != nil {
+			return value, true
+		}
+	}
+	if value := gctx.Request.Context().Value(key); value != nil {
+		return value, true
+	}
+	return nil, false
+}
+
+//missingContextValue context上没有需要的key，作为400返回
+func missingContextValue(key interface{}, fieldType reflect.Type) error {
+	return &StatusError{StatusCode: http.StatusBadRequest, Err: &BindError{
+		Kind:  KindContextMissing,
+		Field: fmt.Sprint(key),
+		Type:  fieldType.String(),
+		Err:   fmt.Errorf("context key %v not found", key),
+	}}
+}
+
+//setContextValue 将context上的值赋给字段，值的类型必须可以直接赋值给字段
+func setContextValue(field reflect.Value, key interface{}, value interface{}) error {
+	v := reflect.ValueOf(value)
+	if !v.Type().AssignableTo(field.Type()) {
+		return fmt.Errorf("context key %v expect %s but get %s", key, field.Type().String(), v.Type().String())
+	}
+	field.Set(v)
+	return nil
+}
+
+//bindContextFields 绑定结构体上带有ctx tag的字段，在gin绑定之后执行，避免被body上的数据覆盖
+func (a *argsInfo) bindContextFields(gctx *gin.Context, argInfo *argTypeInfo, elemValue reflect.Value) error {
+	for i := range argInfo.paramFields {
+		paramField := argInfo.paramFields[i]
+		if paramField.source != Context {
+			continue
+		}
+		filedValue := elemValue.FieldByIndex(paramField.index)
+		value, ok := contextValue(gctx, paramField.name)
+		if !ok {
+			if paramField.omitEmpty {
+				filedValue.Set(reflect.Zero(filedValue.Type()))
+				continue
+			}
+			return missingContextValue(paramField.name, filedValue.Type())
+		}
+		if err := setContextValue(filedValue, paramField.name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//bindContextValue 通过WithContextValue设置的参数
+func bindContextValue(gctx *gin.Context, argInfo *argTypeInfo) (reflect.Value, error) {
+	value, ok := contextValue(gctx, argInfo.contextKey)
+	if !ok {
+		return reflect.Value{}, missingContextValue(argInfo.contextKey, argInfo.argType)
+	}
+	result := reflect.New(argInfo.argType).Elem()
+	if err := setContextValue(result, argInfo.contextKey, value); err != nil {
+		return reflect.Value{}, err
+	}
+	return result, nil
+}
+
+//WithContextValue 类型为T的参数从gin.Context的key或者context.Context的value上获取，
+//key不存在或者类型不匹配时返回错误
+func WithContextValue[T any](key interface{}) CallOption {
+	if key == nil {
+		log.Panic("context key can't null")
+	}
+	valueType := reflect.TypeOf((*T)(nil)).Elem()
+	return func(c *callFunc) {
+		if c.contextValues == nil {
+			c.contextValues = make(map[reflect.Type]interface{})
+		}
+		c.contextValues[valueType] = key
+	}
+}
@@ -0,0 +1,132 @@
+package gbinding
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Credentials 请求中携带的凭证，没有携带的部分为空
+type Credentials struct {
+	//Bearer Authorization: Bearer <token>
+	Bearer string
+	//Username 以及 Password Authorization: Basic <base64(username:password)>
+	Username string
+	Password string
+	//APIKey 通过WithAPIKey设置的header,query或者cookie上的值
+	APIKey string
+}
+
+//结构体字段上auth tag可以使用的值，这些字段只从Authorization以及WithAPIKey设置的位置获取，url以及body上的同名参数不会生效
+const (
+	authBearer   = "bearer"
+	authUsername = "username"
+	authPassword = "password"
+	authAPIKey   = "apikey"
+)
+
+var credentialsType = reflect.TypeOf(Credentials{})
+
+//apiKeySource WithAPIKey设置的api key的位置
+type apiKeySource struct {
+	source Source
+	name   string
+}
+
+//credentials 解析请求中的凭证，Authorization格式错误时返回401的AuthError
+func (a *argsInfo) credentials(gctx *gin.Context) (Credentials, error) {
+	var result Credentials
+	authorization := gctx.GetHeader("Authorization")
+	if scheme, token, ok := cutScheme(authorization); ok {
+		switch {
+		case strings.EqualFold(scheme, "Bearer"):
+			if token == "" {
+				return result, &AuthError{StatusCode: http.StatusUnauthorized, Err: errors.New("empty bearer token")}
+			}
+			result.Bearer = token
+		case strings.EqualFold(scheme, "Basic"):
+			username, password, ok := gctx.Request.BasicAuth()
+			if !ok {
+				return result, &AuthError{StatusCode: http.StatusUnauthorized, Err: errors.New("malformed basic authorization")}
+			}
+			result.Username, result.Password = username, password
+		}
+	}
+	if a.apiKey != nil {
+		switch a.apiKey.source {
+		case Header:
+			result.APIKey = gctx.GetHeader(a.apiKey.name)
+		case Query:
+			result.APIKey = gctx.Query(a.apiKey.name)
+		case Cookie:
+			result.APIKey, _ = gctx.Cookie(a.apiKey.name)
+		}
+	}
+	return result, nil
+}
+
+//cutScheme 拆分 Authorization 的 scheme 和后面的内容
+func cutScheme(authorization string) (string, string, bool) {
+	authorization = strings.TrimSpace(authorization)
+	if authorization == "" {
+		return "", "", false
+	}
+	i := strings.IndexByte(authorization, ' ')
+	if i < 0 {
+		return authorization, "", true
+	}
+	return authorization[:i], strings.TrimSpace(authorization[i+1:]), true
+}
+
+//credentialField 凭证中auth tag对应的值
+func credentialField(c Credentials, name string) string {
+	switch name {
+	case authBearer:
+		return c.Bearer
+	case authUsername:
+		return c.Username
+	case authPassword:
+		return c.Password
+	default:
+		return c.APIKey
+	}
+}
+
+//checkAuthFields 检查结构体上auth tag的字段
+func (a *argsInfo) checkAuthFields(structType reflect.Type, fields []paramField) {
+	for i := range fields {
+		if fields[i].source != Auth {
+			continue
+		}
+		field := structType.FieldByIndex(fields[i].index)
+		switch fields[i].name {
+		case authBearer, authUsername, authPassword:
+		case authAPIKey:
+			if a.apiKey == nil {
+				log.Panicf("struct:%s field:%s auth apikey must set WithAPIKey", structType.String(), field.Name)
+			}
+		default:
+			log.Panicf("struct:%s field:%s unsupport auth tag %s", structType.String(), field.Name, fields[i].name)
+		}
+		if field.Type.Kind() != reflect.String {
+			log.Panicf("struct:%s field:%s auth tag expect string but get %s", structType.String(), field.Name, field.Type.String())
+		}
+	}
+}
+
+//WithAPIKey 设置api key的位置，source只能是Header,Query或者Cookie，
+//可以通过Credentials参数或者结构体字段上的 `auth:"apikey"` 获取
+func WithAPIKey(source Source, name string) CallOption {
+	switch source {
+	case Header, Query, Cookie:
+	default:
+		log.Panicf("api key source expect header,query or cookie but get %s", source)
+	}
+	return func(c *callFunc) {
+		c.asInfo.apiKey = &apiKeySource{source: source, name: name}
+	}
+}
@@ -0,0 +1,287 @@
+package gbinding
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/yaml.v2"
+)
+
+//decodeBody 按照gin的规则选择解码方式，只解码不校验，不会调用binding.Validator，
+//binding以及validate tag在全部来源绑定完之后由validateFields统一校验
+func decodeBody(req *http.Request, obj interface{}) error {
+	switch b := binding.Default(req.Method, contentType(req)); b {
+	case binding.JSON:
+		if req.Body == nil {
+			return fmt.Errorf("invalid request")
+		}
+		return decodeJSON(req.Body, obj)
+	case binding.XML:
+		return xml.NewDecoder(req.Body).Decode(obj)
+	case binding.YAML:
+		return yaml.NewDecoder(req.Body).Decode(obj)
+	case binding.MsgPack:
+		return codec.NewDecoder(req.Body, new(codec.MsgpackHandle)).Decode(obj)
+	case binding.ProtoBuf:
+		//gin的protobuf绑定本身不校验
+		return b.Bind(req, obj)
+	case binding.FormMultipart:
+		if err := req.ParseMultipartForm(defaultMultipartMemory); err != nil {
+			return err
+		}
+		return mapForm(obj, req.MultipartForm.Value)
+	default:
+		if err := req.ParseForm(); err != nil {
+			return err
+		}
+		if err := req.ParseMultipartForm(defaultMultipartMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			return err
+		}
+		return mapForm(obj, req.Form)
+	}
+}
+
+//contentType 去掉参数之后的Content-Type，和gin.Context.ContentType一致
+func contentType(req *http.Request) string {
+	value := req.Header.Get("Content-Type")
+	if i := strings.IndexAny(value, " ;"); i >= 0 {
+		return value[:i]
+	}
+	return value
+}
+
+//decodeJSON 使用和gin一致的json设置解码
+func decodeJSON(r io.Reader, obj interface{}) error {
+	decoder := json.NewDecoder(r)
+	if binding.EnableDecoderUseNumber {
+		decoder.UseNumber()
+	}
+	if binding.EnableDecoderDisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(obj)
+}
+
+//mapForm 按照form tag将表单写入结构体，规则和gin的form绑定一致，文件字段由bindParamFields以及bindStream绑定
+func mapForm(ptr interface{}, form map[string][]string) error {
+	_, err := mapFormValue(reflect.ValueOf(ptr), reflect.StructField{}, form)
+	return err
+}
+
+func mapFormValue(value reflect.Value, field reflect.StructField, form map[string][]string) (bool, error) {
+	if field.Tag.Get("form") == "-" || isFileField(field.Type) || isStoredFileField(field.Type) {
+		return false, nil
+	}
+	if value.Kind() == reflect.Ptr {
+		ptr := value
+		if value.IsNil() {
+			ptr = reflect.New(value.Type().Elem())
+		}
+		set, err := mapFormValue(ptr.Elem(), field, form)
+		if err != nil {
+			return false, err
+		}
+		if set && value.IsNil() {
+			value.Set(ptr)
+		}
+		return set, nil
+	}
+	//匿名的结构体直接展开字段，其他字段先按照自己的名称取值
+	if value.Kind() != reflect.Struct || !field.Anonymous {
+		set, err := setFormField(value, field, form)
+		if err != nil || set {
+			return set, err
+		}
+	}
+	if value.Kind() != reflect.Struct {
+		return false, nil
+	}
+	valueType := value.Type()
+	var set bool
+	for i := 0; i < value.NumField(); i++ {
+		sf := valueType.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		ok, err := mapFormValue(value.Field(i), sf, form)
+		if err != nil {
+			return false, err
+		}
+		set = set || ok
+	}
+	return set, nil
+}
+
+//setFormField 按照form tag的名称取值，没有tag时使用字段名称，form:"name,default=1"在没有值时使用默认值
+func setFormField(value reflect.Value, field reflect.StructField, form map[string][]string) (bool, error) {
+	name, opts, _ := strings.Cut(field.Tag.Get("form"), ",")
+	if name == "" {
+		name = field.Name
+	}
+	if name == "" {
+		return false, nil
+	}
+	var (
+		defaultValue string
+		hasDefault   bool
+	)
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if k, v, _ := strings.Cut(opt, "="); k == "default" {
+			defaultValue, hasDefault = v, true
+		}
+	}
+	values, ok := form[name]
+	if !ok {
+		if !hasDefault {
+			return false, nil
+		}
+		values = []string{defaultValue}
+	}
+	switch value.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(value.Type(), len(values), len(values))
+		if err := setFormArray(values, slice, field); err != nil {
+			return false, err
+		}
+		value.Set(slice)
+		return true, nil
+	case reflect.Array:
+		if len(values) != value.Len() {
+			return false, fmt.Errorf("%q is not valid value for %s", values, value.Type().String())
+		}
+		return true, setFormArray(values, value, field)
+	default:
+		var data string
+		if len(values) > 0 {
+			data = values[0]
+		}
+		return true, setFormString(data, value, field)
+	}
+}
+
+func setFormArray(values []string, array reflect.Value, field reflect.StructField) error {
+	for i := range values {
+		if err := setFormString(values[i], array.Index(i), field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//setFormString 空字符串作为零值，time.Time使用time_format，time_utc以及time_location tag，结构体和map按照json解码
+func setFormString(data string, value reflect.Value, field reflect.StructField) error {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Type() == durationType {
+			d, err := time.ParseDuration(data)
+			if err != nil {
+				return err
+			}
+			value.SetInt(int64(d))
+			return nil
+		}
+		if data == "" {
+			data = "0"
+		}
+		v, err := strconv.ParseInt(data, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if data == "" {
+			data = "0"
+		}
+		v, err := strconv.ParseUint(data, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(v)
+	case reflect.Bool:
+		if data == "" {
+			data = "false"
+		}
+		v, err := strconv.ParseBool(data)
+		if err != nil {
+			return err
+		}
+		value.SetBool(v)
+	case reflect.Float32, reflect.Float64:
+		if data == "" {
+			data = "0"
+		}
+		v, err := strconv.ParseFloat(data, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(v)
+	case reflect.String:
+		value.SetString(data)
+	case reflect.Struct:
+		if value.Type() == timeType {
+			return setFormTime(data, value, field)
+		}
+		return json.Unmarshal([]byte(data), value.Addr().Interface())
+	case reflect.Map:
+		return json.Unmarshal([]byte(data), value.Addr().Interface())
+	default:
+		return fmt.Errorf("unknown type %s", value.Type().String())
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+var timeType = reflect.TypeOf(time.Time{})
+
+func setFormTime(data string, value reflect.Value, field reflect.StructField) error {
+	format := field.Tag.Get("time_format")
+	if format == "" {
+		format = time.RFC3339
+	}
+	switch f := strings.ToLower(format); f {
+	case "unix", "unixnano":
+		v, err := strconv.ParseInt(data, 10, 64)
+		if err != nil {
+			return err
+		}
+		if f == "unix" {
+			value.Set(reflect.ValueOf(time.Unix(v, 0)))
+		} else {
+			value.Set(reflect.ValueOf(time.Unix(0, v)))
+		}
+		return nil
+	}
+	if data == "" {
+		value.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+	location := time.Local
+	if utc, _ := strconv.ParseBool(field.Tag.Get("time_utc")); utc {
+		location = time.UTC
+	}
+	if name := field.Tag.Get("time_location"); name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return err
+		}
+		location = loc
+	}
+	t, err := time.ParseInLocation(format, data, location)
+	if err != nil {
+		return err
+	}
+	value.Set(reflect.ValueOf(t))
+	return nil
+}
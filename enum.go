@@ -0,0 +1,175 @@
+package gbinding
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Enum 只允许固定值的类型，例如
+//
+//	type Status string
+//	func (Status) Enum() []interface{} { return []interface{}{"active", "disabled"} }
+//
+//basic参数在sanitize之后检查，结构体字段在全部来源绑定完之后和validate tag一起检查，不在Enum中的值返回rule为enum的FieldError。
+//结构体字段的零值视为没有传，需要时使用validate:"required"
+type Enum interface {
+	Enum() []interface{}
+}
+
+var enumType = reflect.TypeOf((*Enum)(nil)).Elem()
+
+//EnumValues 类型允许的值，类型或者类型的指针实现了Enum时返回true，可以用于生成文档
+func EnumValues(t reflect.Type) ([]interface{}, bool) {
+	switch {
+	case t.Implements(enumType):
+		return reflect.Zero(t).Interface().(Enum).Enum(), true
+	case t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(enumType):
+		return reflect.New(t).Interface().(Enum).Enum(), true
+	}
+	return nil, false
+}
+
+//FieldEnumValues 结构体字段允许的值，依次是字段类型(包括指针以及slice的元素)上的Enum，validate以及binding tag中的oneof
+func FieldEnumValues(field reflect.StructField) ([]interface{}, bool) {
+	if values, ok := EnumValues(elemType(field.Type)); ok {
+		return values, true
+	}
+	for _, tag := range []string{validateTag, "binding"} {
+		for _, rule := range strings.Split(field.Tag.Get(tag), ",") {
+			if !strings.HasPrefix(rule, "oneof=") {
+				continue
+			}
+			options := strings.Fields(strings.TrimPrefix(rule, "oneof="))
+			values := make([]interface{}, 0, len(options))
+			for i := range options {
+				values = append(values, options[i])
+			}
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+//enumParam 允许的值使用逗号连接，作为FieldError的Param
+func enumParam(values []interface{}) string {
+	params := make([]string, 0, len(values))
+	for i := range values {
+		params = append(params, fmt.Sprint(values[i]))
+	}
+	return strings.Join(params, ",")
+}
+
+//enumError 值不在允许的值中时返回FieldError，按照字符串的形式比较，Enum中可以使用常量或者字面量
+func enumError(value reflect.Value) *FieldError {
+	values, ok := EnumValues(value.Type())
+	if !ok {
+		return nil
+	}
+	data := fmt.Sprint(value.Interface())
+	for i := range values {
+		if fmt.Sprint(values[i]) == data {
+			return nil
+		}
+	}
+	return &FieldError{Rule: "enum", Param: enumParam(values), Value: data}
+}
+
+//checkEnum 转换basic参数之后检查，slice检查每个元素，map检查每个值并使用key作为名称，参数名称由调用方通过withField设置
+func checkEnum(value reflect.Value) error {
+	switch value.Kind() {
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			if err := checkEnum(value.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			if err := checkEnum(iter.Value()); err != nil {
+				return withField(err, iter.Key().String())
+			}
+		}
+		return nil
+	}
+	if fieldErr := enumError(value); fieldErr != nil {
+		return fieldErrorsResult(FieldErrors{fieldErr})
+	}
+	return nil
+}
+
+//enumField 结构体上类型为Enum的字段，nested为嵌套结构体中的字段
+type enumField struct {
+	index  []int
+	name   string
+	enum   bool
+	nested []enumField
+}
+
+//structEnums 收集结构体上类型为Enum的字段
+func structEnums(structType reflect.Type) []enumField {
+	return walkStructFields(structType, func(_ reflect.Type, field reflect.StructField, nested []enumField) (enumField, bool) {
+		result := enumField{index: field.Index, name: requestFieldName(field)}
+		if _, ok := EnumValues(elemType(field.Type)); ok {
+			result.enum = true
+		} else {
+			result.nested = nested
+		}
+		return result, result.enum || len(result.nested) > 0
+	})
+}
+
+//enumErrors 检查结构体上全部Enum字段，返回的Field为请求中的路径，例如 items[0].status
+func (a *argsInfo) enumErrors(gctx *gin.Context, argInfo *argTypeInfo, elemValue reflect.Value) FieldErrors {
+	var fieldErrs FieldErrors
+	for i := range argInfo.enums {
+		field := &argInfo.enums[i]
+		source := a.fieldSource(gctx, argInfo, argInfo.GetBasicType().FieldByIndex(field.index).Name)
+		for _, fieldErr := range collectEnumErrors(elemValue.FieldByIndex(field.index), field, field.name) {
+			fieldErr.Source = source
+			fieldErrs = append(fieldErrs, fieldErr)
+		}
+	}
+	return fieldErrs
+}
+
+func collectEnumErrors(value reflect.Value, field *enumField, path string) FieldErrors {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return nil
+		}
+		return collectEnumErrors(value.Elem(), field, path)
+	case reflect.Slice, reflect.Array:
+		var fieldErrs FieldErrors
+		for i := 0; i < value.Len(); i++ {
+			fieldErrs = append(fieldErrs, collectEnumErrors(value.Index(i), field, path+"["+strconv.Itoa(i)+"]")...)
+		}
+		return fieldErrs
+	}
+	if field.enum {
+		//零值视为没有传
+		if value.IsZero() {
+			return nil
+		}
+		if fieldErr := enumError(value); fieldErr != nil {
+			fieldErr.Field = path
+			return FieldErrors{fieldErr}
+		}
+		return nil
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	var fieldErrs FieldErrors
+	for i := range field.nested {
+		nested := &field.nested[i]
+		fieldErrs = append(fieldErrs, collectEnumErrors(value.FieldByIndex(nested.index), nested, path+"."+nested.name)...)
+	}
+	return fieldErrs
+}
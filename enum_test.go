@@ -0,0 +1,136 @@
+package gbinding
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+type orderStatus string
+
+const (
+	orderPaid    orderStatus = "paid"
+	orderShipped orderStatus = "shipped"
+)
+
+func (orderStatus) Enum() []interface{} {
+	return []interface{}{orderPaid, orderShipped}
+}
+
+type priority int
+
+func (*priority) Enum() []interface{} {
+	return []interface{}{1, 2, 3}
+}
+
+func TestEnumValues(t *testing.T) {
+	values, ok := EnumValues(reflect.TypeOf(orderPaid))
+	assert.Equal(t, ok, true)
+	assert.Equal(t, values, []interface{}{orderPaid, orderShipped})
+	values, ok = EnumValues(reflect.TypeOf(priority(0)))
+	assert.Equal(t, ok, true)
+	assert.Equal(t, values, []interface{}{1, 2, 3})
+	_, ok = EnumValues(reflect.TypeOf(""))
+	assert.Equal(t, ok, false)
+
+	type query struct {
+		Status []orderStatus `form:"status"`
+		Sort   string        `form:"sort" validate:"omitempty,oneof=created price"`
+		Name   string        `form:"name"`
+	}
+	queryType := reflect.TypeOf(query{})
+	values, ok = FieldEnumValues(queryType.Field(0))
+	assert.Equal(t, ok, true)
+	assert.Equal(t, values, []interface{}{orderPaid, orderShipped})
+	values, ok = FieldEnumValues(queryType.Field(1))
+	assert.Equal(t, ok, true)
+	assert.Equal(t, values, []interface{}{"created", "price"})
+	_, ok = FieldEnumValues(queryType.Field(2))
+	assert.Equal(t, ok, false)
+}
+
+func TestBindingAndInvoke_enum(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+	fieldErrors := func(t *testing.T) FieldErrors {
+		var fieldErrs FieldErrors
+		assert.Equal(t, errors.As(lastErr, &fieldErrs), true)
+		return fieldErrs
+	}
+
+	t.Run("basic", func(t *testing.T) {
+		var got []orderStatus
+		handler := BindingAndInvoke(func(status []orderStatus) error {
+			got = status
+			return nil
+		}, WithQueryName("status"))
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?status=paid&status=shipped", nil))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, []orderStatus{orderPaid, orderShipped})
+
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?status=paid&status=lost", nil))
+		assert.Equal(t, fieldErrors(t), FieldErrors{{Field: "status", Rule: "enum", Param: "paid,shipped", Value: "lost"}})
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		type item struct {
+			Priority priority `json:"priority"`
+		}
+		type order struct {
+			ID     int64       `uri:"id"`
+			Status orderStatus `json:"status"`
+			Items  []item      `json:"items"`
+		}
+		handler := BindingAndInvoke(func(o order) error {
+			return nil
+		})
+		newReq := func(body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/1", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			return req
+		}
+		//没有传的字段不检查
+		serve(http.MethodPost, "/:id", handler, newReq(`{"items":[{"priority":1}]}`))
+		assert.Equal(t, lastErr, nil)
+
+		serve(http.MethodPost, "/:id", handler, newReq(`{"status":"lost","items":[{"priority":1},{"priority":5}]}`))
+		assert.Equal(t, fieldErrors(t), FieldErrors{
+			{Field: "status", Source: Body, Rule: "enum", Param: "paid,shipped", Value: "lost"},
+			{Field: "items[1].priority", Source: Body, Rule: "enum", Param: "1,2,3", Value: "5"},
+		})
+	})
+
+	//先转换再检查
+	t.Run("sanitize", func(t *testing.T) {
+		var got orderStatus
+		handler := BindingAndInvoke(func(status orderStatus) error {
+			got = status
+			return nil
+		}, WithQueryName("status"), WithSanitizers("trim", "lower"))
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?status=%20Paid%20", nil))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, orderPaid)
+
+		type request struct {
+			Status orderStatus `header:"X-S" sanitize:"trim,lower"`
+		}
+		var gotReq request
+		handler = BindingAndInvoke(func(r request) error {
+			gotReq = r
+			return nil
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-S", " Shipped ")
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, gotReq.Status, orderShipped)
+	})
+}
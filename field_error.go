@@ -0,0 +1,60 @@
+package gbinding
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//FieldError 单个字段不满足约束的原因
+type FieldError struct {
+	//Field 请求中的字段名，例如form上的名称，嵌套字段使用.连接，例如 items[0].name
+	Field string
+	//Source 字段的来源，例如 path,header,body
+	Source Source
+	//Rule 不满足的约束，例如 maxSize,accept
+	Rule string
+	//Param 约束的参数，例如 5MB
+	Param string
+	//Value 导致失败的值，例如文件名或者检测到的MIME类型
+	Value string
+}
+
+func (e *FieldError) Error() string {
+	if e.Param == "" {
+		return fmt.Sprintf("field %s: %s failed, value %s", e.Field, e.Rule, e.Value)
+	}
+	return fmt.Sprintf("field %s: %s=%s failed, value %s", e.Field, e.Rule, e.Param, e.Value)
+}
+
+//Localize 按照Rule取出对应语言的消息
+func (e *FieldError) Localize(lang string) string {
+	return formatMessage(lang, e.Rule, map[string]string{"field": e.Field, "source": string(e.Source), "rule": e.Rule, "param": e.Param, "value": e.Value})
+}
+
+//FieldErrors 同一个请求中全部字段的错误，包装在400的StatusError中，可以通过errors.As取出
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for i := range e {
+		messages = append(messages, e[i].Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e FieldErrors) Localize(lang string) string {
+	messages := make([]string, 0, len(e))
+	for i := range e {
+		messages = append(messages, e[i].Localize(lang))
+	}
+	return strings.Join(messages, "; ")
+}
+
+//fieldErrorsResult 有字段错误时包装成400的StatusError
+func fieldErrorsResult(fieldErrs FieldErrors) error {
+	if len(fieldErrs) == 0 {
+		return nil
+	}
+	return &StatusError{StatusCode: http.StatusBadRequest, Err: fieldErrs}
+}
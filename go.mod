@@ -1,9 +1,24 @@
 module github.com/optimistic9527/gbinding
 
-go 1.16
+go 1.18
 
 require (
 	github.com/gin-gonic/gin v1.7.2
 	github.com/go-playground/assert/v2 v2.0.1
+	github.com/go-playground/validator/v10 v10.4.1
 	github.com/spf13/cast v1.3.1
+	github.com/ugorji/go/codec v1.1.7
+	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v2 v2.2.8
+)
+
+require (
+	github.com/gin-contrib/sse v0.1.0 // indirect
+	github.com/go-playground/locales v0.13.0 // indirect
+	github.com/go-playground/universal-translator v0.17.0 // indirect
+	github.com/golang/protobuf v1.3.3 // indirect
+	github.com/leodido/go-urn v1.2.0 // indirect
+	github.com/mattn/go-isatty v0.0.12 // indirect
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9 // indirect
+	golang.org/x/sys v0.5.0 // indirect
 )
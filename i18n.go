@@ -0,0 +1,237 @@
+package gbinding
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//错误的类型，作为消息目录的key。FieldError使用Rule作为key，没有对应的消息时使用KindInvalid
+const (
+	//KindMissing 参数不存在
+	KindMissing = "missing"
+	//KindInvalidValue 参数的值无法转换成需要的类型
+	KindInvalidValue = "invalidValue"
+	//KindInvalid 没有单独消息的校验规则
+	KindInvalid = "invalid"
+	//KindConflict WithStrictSources时多个来源上的值不一致
+	KindConflict = "conflict"
+	//KindContextMissing gin.Context以及context.Context上都没有需要的key
+	KindContextMissing = "contextMissing"
+)
+
+//languageKey 通过WithLanguage设置的默认语言保存在gin.Context上的key
+const languageKey = "gbinding.language"
+
+//defaultLanguage 请求以及handler都没有指定语言时使用
+var defaultLanguage = "en"
+
+//messages 每种语言的消息目录，消息中可以使用 {field} {source} {rule} {param} {value} {type}
+var messages = map[string]map[string]string{
+	"en": {
+		KindMissing:        "{field} is required",
+		KindInvalidValue:   "invalid value {value} for {field}",
+		KindInvalid:        "{field} failed on {rule} {param}",
+		KindConflict:       "{field} has conflicting values {value}",
+		KindContextMissing: "{field} is missing from context",
+		"required":         "{field} is required",
+		"min":              "{field} must be at least {param}",
+		"max":              "{field} must be at most {param}",
+		"len":              "{field} must be {param} in length",
+		"gt":               "{field} must be greater than {param}",
+		"gte":              "{field} must be greater than or equal to {param}",
+		"lt":               "{field} must be less than {param}",
+		"lte":              "{field} must be less than or equal to {param}",
+		"oneof":            "{field} must be one of [{param}]",
+		"email":            "{field} must be a valid email address",
+		"enum":             "{field} must be one of [{param}]",
+		"maxSize":          "file {value} of {field} exceeds the size limit {param}",
+		"maxFiles":         "{field} accepts at most {param} files",
+		"accept":           "{field} does not accept files of type {value}",
+		"ext":              "{field} does not accept file {value}",
+	},
+	"zh": {
+		KindMissing:        "{field}不能为空",
+		KindInvalidValue:   "{field}的值{value}无效",
+		KindInvalid:        "{field}不满足{rule}{param}",
+		KindConflict:       "{field}的值{value}不一致",
+		KindContextMissing: "上下文中缺少{field}",
+		"required":         "{field}为必填字段",
+		"min":              "{field}最小为{param}",
+		"max":              "{field}最大为{param}",
+		"len":              "{field}的长度必须为{param}",
+		"gt":               "{field}必须大于{param}",
+		"gte":              "{field}必须大于或等于{param}",
+		"lt":               "{field}必须小于{param}",
+		"lte":              "{field}必须小于或等于{param}",
+		"oneof":            "{field}必须是[{param}]中的一个",
+		"email":            "{field}必须是有效的邮箱地址",
+		"enum":             "{field}必须是[{param}]中的一个",
+		"maxSize":          "{field}的文件{value}超过了大小限制{param}",
+		"maxFiles":         "{field}最多只能上传{param}个文件",
+		"accept":           "{field}不支持{value}类型的文件",
+		"ext":              "{field}不支持文件{value}",
+	},
+}
+
+//localizer 可以按照语言生成消息的错误
+type localizer interface {
+	Localize(lang string) string
+}
+
+//RegisterMessages 注册新的语言或者覆盖已有语言中的消息，key为Kind开头的常量或者校验规则的名称
+func RegisterMessages(lang string, catalog map[string]string) {
+	lang = strings.ToLower(lang)
+	if messages[lang] == nil {
+		messages[lang] = map[string]string{}
+	}
+	for kind, message := range catalog {
+		messages[lang][kind] = message
+	}
+}
+
+//SetDefaultLanguage 请求的Accept-Language以及WithLanguage都没有可用的语言时使用，默认为en
+func SetDefaultLanguage(lang string) {
+	defaultLanguage = checkLanguage(lang)
+}
+
+//WithLanguage 设置该handler的默认语言，Accept-Language中有已注册的语言时仍然优先使用
+func WithLanguage(lang string) CallOption {
+	lang = checkLanguage(lang)
+	return func(c *callFunc) {
+		c.language = lang
+	}
+}
+
+func checkLanguage(lang string) string {
+	lang = strings.ToLower(lang)
+	if messages[lang] == nil {
+		log.Panicf("language %s has no messages, please RegisterMessages first", lang)
+	}
+	return lang
+}
+
+//Language 当前请求使用的语言，依次是Accept-Language，WithLanguage，SetDefaultLanguage
+func Language(gctx *gin.Context) string {
+	if lang, ok := acceptLanguage(gctx.GetHeader("Accept-Language")); ok {
+		return lang
+	}
+	if lang := gctx.GetString(languageKey); lang != "" {
+		return lang
+	}
+	return defaultLanguage
+}
+
+//Message 将错误转换成当前请求语言的消息，没有对应消息的错误返回Error()
+func Message(gctx *gin.Context, err error) string {
+	var l localizer
+	if errors.As(err, &l) {
+		return l.Localize(Language(gctx))
+	}
+	return err.Error()
+}
+
+//acceptLanguage 按照q值从大到小取第一个已注册的语言，zh-CN没有注册时使用zh
+func acceptLanguage(header string) (string, bool) {
+	if header == "" {
+		return "", false
+	}
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if lang == "" || q <= 0 {
+			continue
+		}
+		langs = append(langs, weighted{lang: strings.ToLower(lang), q: q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+	for _, l := range langs {
+		if messages[l.lang] != nil {
+			return l.lang, true
+		}
+		if base, _, ok := strings.Cut(l.lang, "-"); ok && messages[base] != nil {
+			return base, true
+		}
+	}
+	return "", false
+}
+
+//formatMessage 按照语言以及类型取出消息并替换参数，语言中没有时使用英文
+func formatMessage(lang, kind string, args map[string]string) string {
+	message, ok := messages[lang][kind]
+	if !ok {
+		message, ok = messages["en"][kind]
+	}
+	if !ok {
+		return formatMessage(lang, KindInvalid, args)
+	}
+	pairs := make([]string, 0, len(args)*2)
+	for k, v := range args {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(message)
+}
+
+//BindError 参数无法绑定的原因，Error()返回给开发者的信息，Localize返回给用户的信息
+type BindError struct {
+	//Kind KindMissing，KindInvalidValue，KindConflict或者KindContextMissing
+	Kind string
+	//Field 参数的名称，没有名称时为参数的类型
+	Field string
+	//Type 需要的类型
+	Type string
+	//Value 请求中的值
+	Value string
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BindError) Localize(lang string) string {
+	field := e.Field
+	if field == "" {
+		field = e.Type
+	}
+	return formatMessage(lang, e.Kind, map[string]string{"field": field, "type": e.Type, "value": e.Value})
+}
+
+//withField 给绑定错误以及字段错误加上参数名称
+func withField(err error, name string) error {
+	var bindErr *BindError
+	if errors.As(err, &bindErr) && bindErr.Field == "" {
+		bindErr.Field = name
+	}
+	var fieldErrs FieldErrors
+	if errors.As(err, &fieldErrs) {
+		for i := range fieldErrs {
+			if fieldErrs[i].Field == "" {
+				fieldErrs[i].Field = name
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package gbinding
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_acceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+		ok     bool
+	}{
+		{"", "", false},
+		{"zh-CN,zh;q=0.9,en;q=0.8", "zh", true},
+		{"fr;q=0.9,en-US;q=0.8", "en", true},
+		{"en;q=0.5,zh;q=0.9", "zh", true},
+		{"fr,de", "", false},
+		{"zh;q=0", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := acceptLanguage(tt.header)
+		assert.Equal(t, got, tt.want)
+		assert.Equal(t, ok, tt.ok)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	var message string
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		message = ""
+		if err != nil {
+			message = Message(ctx, err)
+		}
+	})
+	newReq := func(target, lang string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if lang != "" {
+			req.Header.Set("Accept-Language", lang)
+		}
+		return req
+	}
+
+	t.Run("bind error", func(t *testing.T) {
+		handler := BindingAndInvoke(func(page int) error {
+			return nil
+		}, WithQueryName("page"))
+		serve(http.MethodGet, "/", handler, newReq("/", ""))
+		assert.Equal(t, message, "page is required")
+		serve(http.MethodGet, "/", handler, newReq("/?page=a", "zh-CN"))
+		assert.Equal(t, message, "page的值a无效")
+
+		type request struct {
+			ID int `uri:"id"`
+		}
+		handler = BindingAndInvoke(func(r request) error {
+			return nil
+		})
+		serve(http.MethodGet, "/:id", handler, newReq("/a", "en"))
+		assert.Equal(t, message, "invalid value a for id")
+	})
+
+	t.Run("conflict and context", func(t *testing.T) {
+		handler := BindingAndInvoke(func(tenant string) error {
+			return nil
+		}, WithQueryName("tenant"), WithHeaderNames("X-Tenant"), WithStrictSources())
+		req := newReq("/?tenant=a", "zh")
+		req.Header.Set("X-Tenant", "b")
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, message, `tenant的值query="a", header="b"不一致`)
+
+		handler = BindingAndInvoke(func(u *testUser) error {
+			return nil
+		}, WithContextValue[*testUser]("user"))
+		serve(http.MethodGet, "/", handler, newReq("/", "en"))
+		assert.Equal(t, message, "user is missing from context")
+	})
+
+	t.Run("field errors", func(t *testing.T) {
+		type request struct {
+			Name string `form:"name" validate:"required"`
+			Age  int    `form:"age" validate:"gte=18"`
+		}
+		handler := BindingAndInvoke(func(r request) error {
+			return nil
+		}, WithLanguage("zh"))
+		serve(http.MethodGet, "/", handler, newReq("/?age=1", ""))
+		assert.Equal(t, message, "name为必填字段; age必须大于或等于18")
+		serve(http.MethodGet, "/", handler, newReq("/?age=1", "en-GB"))
+		assert.Equal(t, message, "name is required; age must be greater than or equal to 18")
+	})
+
+	//gin的binding tag同样转换成FieldErrors
+	t.Run("binding tag", func(t *testing.T) {
+		type request struct {
+			Name string `form:"name" binding:"required"`
+			Age  int    `form:"age" binding:"gte=18"`
+		}
+		handler := BindingAndInvoke(func(r request) error {
+			return nil
+		}, WithLanguage("zh"))
+		serve(http.MethodGet, "/", handler, newReq("/?age=1", ""))
+		assert.Equal(t, message, "name为必填字段; age必须大于或等于18")
+	})
+
+	t.Run("register", func(t *testing.T) {
+		RegisterMessages("ja", map[string]string{"required": "{field}は必須です"})
+		defer delete(messages, "ja")
+		handler := BindingAndInvoke(func(page int) error {
+			return nil
+		}, WithQueryName("page"), WithLanguage("ja"))
+		//没有翻译的消息使用英文
+		serve(http.MethodGet, "/", handler, newReq("/", ""))
+		assert.Equal(t, message, "page is required")
+
+		type request struct {
+			Name string `form:"name" validate:"required"`
+		}
+		handler = BindingAndInvoke(func(r request) error {
+			return nil
+		})
+		serve(http.MethodGet, "/", handler, newReq("/", "ja-JP"))
+		assert.Equal(t, message, "nameは必須です")
+	})
+}
@@ -0,0 +1,42 @@
+package gbinding
+
+import (
+	"log"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+//providerFunc 每次请求时获取注入参数的值
+type providerFunc func(gctx *gin.Context) (reflect.Value, error)
+
+//providers 注册的可注入类型，需要在BindingAndInvoke之前注册
+var providers = map[reflect.Type]providerFunc{}
+
+//Provide 注册一个可注入的类型，handler的参数中有该类型时，每次请求都会调用provider获取，
+//provider返回的错误会交给ResponseHandler处理。必须在BindingAndInvoke之前调用，同一个类型重复注册时后面的覆盖前面的
+func Provide[T any](provider func(gctx *gin.Context) (T, error)) {
+	if provider == nil {
+		log.Panic("provider can't null")
+	}
+	provideType := reflect.TypeOf((*T)(nil)).Elem()
+	switch provideType {
+	case httpRequestType, rsWriterType, contextType, ginContextType:
+		log.Panicf("type %s is builtin, can't provide", provideType.String())
+	}
+	providers[provideType] = func(gctx *gin.Context) (reflect.Value, error) {
+		value, err := provider(gctx)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		//通过指针取值，保证接口类型的值也是接口类型
+		return reflect.ValueOf(&value).Elem(), nil
+	}
+}
+
+//ProvideValue 注册一个固定值的可注入类型，适用于*sql.DB这种全局共享的依赖
+func ProvideValue[T any](value T) {
+	Provide(func(gctx *gin.Context) (T, error) {
+		return value, nil
+	})
+}
@@ -0,0 +1,302 @@
+package gbinding
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+//jwt校验失败的原因，都会包装在401的AuthError中，可以通过errors.Is判断
+var (
+	ErrTokenMissing     = errors.New("jwt: token missing")
+	ErrTokenMalformed   = errors.New("jwt: token malformed")
+	ErrTokenAlgorithm   = errors.New("jwt: unsupported algorithm")
+	ErrTokenSignature   = errors.New("jwt: signature invalid")
+	ErrTokenExpired     = errors.New("jwt: token expired")
+	ErrTokenNotValidYet = errors.New("jwt: token not valid yet")
+	ErrTokenIssuer      = errors.New("jwt: issuer invalid")
+	ErrTokenAudience    = errors.New("jwt: audience invalid")
+)
+
+//JWTHeader jwt的header
+type JWTHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+//JWTKeyFunc 根据header返回验证签名的key，HS256为[]byte，RS256为*rsa.PublicKey，ES256为*ecdsa.PublicKey，
+//算法和key的类型不一致时校验失败
+type JWTKeyFunc func(header JWTHeader) (interface{}, error)
+
+//JWTOption jwt的校验选项
+type JWTOption func(c *jwtConfig)
+
+type jwtConfig struct {
+	keyFunc    JWTKeyFunc
+	claimsType reflect.Type
+	issuer     string
+	audience   string
+	clockSkew  time.Duration
+	now        func() time.Time
+}
+
+//jwtKey 同一个请求中校验过的claims缓存在gin.Context上
+const jwtKey = "gbinding.jwt"
+
+//jwtClaims 校验过的claims，raw用于绑定结构体上的claim tag
+type jwtClaims struct {
+	payload []byte
+	raw     map[string]interface{}
+}
+
+//WithJWT 校验Authorization中的bearer jwt，claims会绑定到类型为C或者*C的参数上，以及结构体上带有 `claim:"sub"` tag的字段，
+//这些字段只从token获取，url以及body上的同名参数不会生效。校验失败时返回401的AuthError
+func WithJWT[C any](keyFunc JWTKeyFunc, opts ...JWTOption) CallOption {
+	if keyFunc == nil {
+		log.Panic("jwt keyFunc can't null")
+	}
+	config := &jwtConfig{
+		keyFunc:    keyFunc,
+		claimsType: reflect.TypeOf((*C)(nil)).Elem(),
+		now:        time.Now,
+	}
+	for i := range opts {
+		opts[i](config)
+	}
+	return func(c *callFunc) {
+		c.asInfo.jwt = config
+	}
+}
+
+//JWTIssuer iss必须一致
+func JWTIssuer(issuer string) JWTOption {
+	return func(c *jwtConfig) {
+		c.issuer = issuer
+	}
+}
+
+//JWTAudience aud中必须包含audience
+func JWTAudience(audience string) JWTOption {
+	return func(c *jwtConfig) {
+		c.audience = audience
+	}
+}
+
+//JWTClockSkew 校验exp以及nbf时允许的时钟误差
+func JWTClockSkew(skew time.Duration) JWTOption {
+	return func(c *jwtConfig) {
+		c.clockSkew = skew
+	}
+}
+
+//JWTNow 设置校验时使用的当前时间，一般用于测试
+func JWTNow(now func() time.Time) JWTOption {
+	return func(c *jwtConfig) {
+		c.now = now
+	}
+}
+
+//isClaims 参数是否是WithJWT设置的claims类型
+func (c *jwtConfig) isClaims(argType reflect.Type) bool {
+	return argType == c.claimsType || (argType.Kind() == reflect.Ptr && argType.Elem() == c.claimsType)
+}
+
+//claims 校验当前请求的jwt，同一个请求只校验一次
+func (c *jwtConfig) claims(gctx *gin.Context) (*jwtClaims, error) {
+	if cached, ok := gctx.Get(jwtKey); ok {
+		return cached.(*jwtClaims), nil
+	}
+	scheme, token, ok := cutScheme(gctx.GetHeader("Authorization"))
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, &AuthError{StatusCode: http.StatusUnauthorized, Err: ErrTokenMissing}
+	}
+	claims, err := c.verify(token)
+	if err != nil {
+		return nil, &AuthError{StatusCode: http.StatusUnauthorized, Err: err}
+	}
+	gctx.Set(jwtKey, claims)
+	return claims, nil
+}
+
+func (c *jwtConfig) verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	var header JWTHeader
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	key, err := c.keyFunc(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenSignature, err)
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	claims := &jwtClaims{payload: payload}
+	if err := json.Unmarshal(payload, &claims.raw); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err := c.checkClaims(claims.raw); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+//verifySignature 只支持HS256,RS256,ES256，并且key的类型必须和算法一致，避免算法混淆
+func verifySignature(alg string, key interface{}, signingInput string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+	switch alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrTokenAlgorithm
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrTokenSignature
+		}
+	case "RS256":
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrTokenAlgorithm
+		}
+		if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) != nil {
+			return ErrTokenSignature
+		}
+	case "ES256":
+		publicKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || publicKey.Curve != elliptic.P256() {
+			return ErrTokenAlgorithm
+		}
+		if len(signature) != 64 {
+			return ErrTokenSignature
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(publicKey, digest[:], r, s) {
+			return ErrTokenSignature
+		}
+	default:
+		return ErrTokenAlgorithm
+	}
+	return nil
+}
+
+//checkClaims 校验exp,nbf,iss以及aud
+func (c *jwtConfig) checkClaims(raw map[string]interface{}) error {
+	now := c.now()
+	if exp, ok := raw["exp"]; ok {
+		expAt, err := cast.ToInt64E(exp)
+		if err != nil {
+			return ErrTokenMalformed
+		}
+		if now.After(time.Unix(expAt, 0).Add(c.clockSkew)) {
+			return ErrTokenExpired
+		}
+	}
+	if nbf, ok := raw["nbf"]; ok {
+		nbfAt, err := cast.ToInt64E(nbf)
+		if err != nil {
+			return ErrTokenMalformed
+		}
+		if now.Before(time.Unix(nbfAt, 0).Add(-c.clockSkew)) {
+			return ErrTokenNotValidYet
+		}
+	}
+	if c.issuer != "" {
+		if iss, _ := raw["iss"].(string); iss != c.issuer {
+			return ErrTokenIssuer
+		}
+	}
+	if c.audience != "" {
+		var audiences []string
+		switch aud := raw["aud"].(type) {
+		case string:
+			audiences = []string{aud}
+		case []interface{}:
+			audiences = cast.ToStringSlice(aud)
+		}
+		if !contains(audiences, c.audience) {
+			return ErrTokenAudience
+		}
+	}
+	return nil
+}
+
+//checkClaimFields 结构体上有claim tag的字段时必须设置WithJWT
+func (a *argsInfo) checkClaimFields(structType reflect.Type, fields []paramField) {
+	for i := range fields {
+		if fields[i].source == Claim && a.jwt == nil {
+			log.Panicf("struct:%s field:%s claim tag must set WithJWT", structType.String(), structType.FieldByIndex(fields[i].index).Name)
+		}
+	}
+}
+
+//bindClaims 绑定WithJWT设置的claims参数
+func (c *jwtConfig) bindClaims(gctx *gin.Context, argType reflect.Type) (reflect.Value, error) {
+	claims, err := c.claims(gctx)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	value := reflect.New(c.claimsType)
+	if err := json.Unmarshal(claims.payload, value.Interface()); err != nil {
+		return reflect.Value{}, &AuthError{StatusCode: http.StatusUnauthorized, Err: fmt.Errorf("%w: %v", ErrTokenMalformed, err)}
+	}
+	if argType.Kind() == reflect.Ptr {
+		return value, nil
+	}
+	return value.Elem(), nil
+}
+
+//setClaimField 将claim的值赋给字段，基础类型通过字符串转换，其他类型通过json转换，claim不存在时保持零值
+func setClaimField(field reflect.Value, claims *jwtClaims, name string) error {
+	claim, ok := claims.raw[name]
+	if !ok || claim == nil {
+		return nil
+	}
+	if isBasicKind(field.Kind()) {
+		data, err := cast.ToStringE(claim)
+		if err != nil {
+			return err
+		}
+		return setBasicValue(field, data)
+	}
+	data, err := json.Marshal(claim)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, field.Addr().Interface())
+}
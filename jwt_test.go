@@ -0,0 +1,184 @@
+package gbinding
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func signTestJWT(t *testing.T, alg string, key interface{}, claims map[string]interface{}) string {
+	header, _ := json.Marshal(JWTHeader{Alg: alg, Typ: "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func Test_jwtConfig_verify(t *testing.T) {
+	now := time.Unix(1000, 0)
+	secret := []byte("secret")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	keys := map[string]interface{}{"HS256": secret, "RS256": &rsaKey.PublicKey, "ES256": &ecKey.PublicKey}
+	config := &jwtConfig{
+		keyFunc: func(header JWTHeader) (interface{}, error) {
+			return keys[header.Alg], nil
+		},
+		issuer:    "gbinding",
+		audience:  "api",
+		clockSkew: 10 * time.Second,
+		now:       func() time.Time { return now },
+	}
+	valid := map[string]interface{}{"sub": "tom", "iss": "gbinding", "aud": []string{"web", "api"}, "exp": 1005, "nbf": 1005}
+
+	tests := []struct {
+		name   string
+		token  string
+		expect error
+	}{
+		{"HS256", signTestJWT(t, "HS256", secret, valid), nil},
+		{"RS256", signTestJWT(t, "RS256", rsaKey, valid), nil},
+		{"ES256", signTestJWT(t, "ES256", ecKey, valid), nil},
+		{"signature", signTestJWT(t, "HS256", []byte("other"), valid), ErrTokenSignature},
+		{"none algorithm", signTestJWT(t, "none", nil, valid), ErrTokenAlgorithm},
+		{"malformed", "a.b", ErrTokenMalformed},
+		{"expired", signTestJWT(t, "HS256", secret, map[string]interface{}{"iss": "gbinding", "aud": "api", "exp": 989}), ErrTokenExpired},
+		{"not valid yet", signTestJWT(t, "HS256", secret, map[string]interface{}{"iss": "gbinding", "aud": "api", "nbf": 1011}), ErrTokenNotValidYet},
+		{"issuer", signTestJWT(t, "HS256", secret, map[string]interface{}{"iss": "other", "aud": "api"}), ErrTokenIssuer},
+		{"audience", signTestJWT(t, "HS256", secret, map[string]interface{}{"iss": "gbinding", "aud": "web"}), ErrTokenAudience},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.verify(tt.token)
+			assert.Equal(t, errors.Is(err, tt.expect), true)
+		})
+	}
+
+	t.Run("key type mismatch", func(t *testing.T) {
+		keys["RS256"] = secret
+		defer func() {
+			keys["RS256"] = &rsaKey.PublicKey
+		}()
+		_, err := config.verify(signTestJWT(t, "RS256", rsaKey, valid))
+		assert.Equal(t, err, ErrTokenAlgorithm)
+	})
+}
+
+type testClaims struct {
+	Subject string   `json:"sub"`
+	Roles   []string `json:"roles"`
+}
+
+func TestBindingAndInvoke_jwt(t *testing.T) {
+	var lastErr error
+	var lastData interface{}
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastData, lastErr = data, err
+	})
+	secret := []byte("secret")
+	keyFunc := func(header JWTHeader) (interface{}, error) {
+		return secret, nil
+	}
+	type request struct {
+		Subject string   `claim:"sub"`
+		Exp     int64    `claim:"exp"`
+		Roles   []string `claim:"roles"`
+		Name    string   `form:"name"`
+	}
+	handler := BindingAndInvoke(func(c *testClaims, r request) (string, error) {
+		return c.Subject + " " + r.Subject + " " + r.Roles[0] + " " + r.Name, nil
+	}, WithJWT[testClaims](keyFunc))
+	exp := time.Now().Add(time.Hour).Unix()
+	token := signTestJWT(t, "HS256", secret, map[string]interface{}{"sub": "tom", "exp": exp, "roles": []string{"admin"}})
+
+	t.Run("bind", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?name=x", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, "tom tom admin x")
+	})
+
+	//claim tag的字段只从校验过的token获取，token中没有的claim为零值
+	t.Run("claims ignore query and body", func(t *testing.T) {
+		type claimRequest struct {
+			Subject string   `claim:"sub"`
+			Roles   []string `claim:"roles"`
+			Team    string   `claim:"team"`
+			Name    string   `form:"name" json:"name"`
+		}
+		handler := BindingAndInvoke(func(r claimRequest) (claimRequest, error) {
+			return r, nil
+		}, WithJWT[testClaims](keyFunc))
+		token := signTestJWT(t, "HS256", secret, map[string]interface{}{"sub": "tom", "exp": exp, "roles": []string{"user"}})
+		expect := claimRequest{Subject: "tom", Roles: []string{"user"}, Name: "x"}
+
+		req := httptest.NewRequest(http.MethodGet, "/?Subject=admin&Roles=admin&Team=evil&name=x", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		serve(http.MethodGet, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, expect)
+
+		req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Subject":"admin","Roles":["admin"],"Team":"evil","name":"x"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		serve(http.MethodPost, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, expect)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		handler := BindingAndInvoke(func() error {
+			return nil
+		}, WithJWT[testClaims](keyFunc))
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/", nil))
+		var authErr *AuthError
+		assert.Equal(t, errors.As(lastErr, &authErr), true)
+		assert.Equal(t, authErr.StatusCode, http.StatusUnauthorized)
+		assert.Equal(t, errors.Is(lastErr, ErrTokenMissing), true)
+	})
+
+	t.Run("claim without jwt", func(t *testing.T) {
+		defer func() {
+			assert.Equal(t, recover().(string), "struct:gbinding.request field:Subject claim tag must set WithJWT")
+		}()
+		BindingAndInvoke(func(r request) error {
+			return nil
+		})
+	})
+}
@@ -0,0 +1,166 @@
+package gbinding
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//超过限制时的原因，包装在400的StatusError中，可以通过errors.Is判断
+var (
+	ErrTooManyQueryParams = errors.New("too many query params")
+	ErrSliceTooLong       = errors.New("too many values")
+	ErrHeaderTooLong      = errors.New("header value too long")
+)
+
+//limitedBody 读取超过限制时返回413的StatusError，并记录下来，绑定失败时以413为准
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, &StatusError{StatusCode: http.StatusRequestEntityTooLarge, Err: ErrBodyTooLarge}
+	}
+	//多读一个字节用于判断是否超过限制
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.ReadCloser.Read(p)
+	if int64(n) > l.remaining {
+		l.exceeded = true
+		return int(l.remaining), &StatusError{StatusCode: http.StatusRequestEntityTooLarge, Err: ErrBodyTooLarge}
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
+
+//WithMaxMultipartMemory 解析multipart时保存在内存中的最大字节数，超过的部分写入临时文件，不设置时使用gin的MaxMultipartMemory
+func WithMaxMultipartMemory(maxMemory int64) CallOption {
+	return func(c *callFunc) {
+		c.asInfo.maxMultipartMemory = maxMemory
+	}
+}
+
+//WithMaxSliceLen 绑定slice以及map时值的最大数量，超过时返回400的StatusError
+func WithMaxSliceLen(maxLen int) CallOption {
+	return func(c *callFunc) {
+		c.asInfo.maxSliceLen = maxLen
+	}
+}
+
+//WithMaxQueryParams url上参数的最大数量，重复的key分别计算，超过时返回400的StatusError
+func WithMaxQueryParams(maxParams int) CallOption {
+	return func(c *callFunc) {
+		c.asInfo.maxQueryParams = maxParams
+	}
+}
+
+//WithMaxHeaderValueLen 请求中每个header值的最大长度，超过时返回400的StatusError
+func WithMaxHeaderValueLen(maxLen int) CallOption {
+	return func(c *callFunc) {
+		c.asInfo.maxHeaderValueLen = maxLen
+	}
+}
+
+//checkLimits 绑定之前检查url以及header，并限制body的读取
+func (a *argsInfo) checkLimits(gctx *gin.Context) error {
+	req := gctx.Request
+	if a.maxQueryParams > 0 && req.URL.RawQuery != "" {
+		//不解析url，直接按照分隔符计算数量
+		if count := strings.Count(req.URL.RawQuery, "&") + 1; count > a.maxQueryParams {
+			return &StatusError{StatusCode: http.StatusBadRequest, Err: fmt.Errorf("%w: %d, limit %d", ErrTooManyQueryParams, count, a.maxQueryParams)}
+		}
+	}
+	if a.maxHeaderValueLen > 0 {
+		for name, values := range req.Header {
+			for i := range values {
+				if len(values[i]) > a.maxHeaderValueLen {
+					return &StatusError{StatusCode: http.StatusBadRequest, Err: fmt.Errorf("%w: %s, limit %d", ErrHeaderTooLong, name, a.maxHeaderValueLen)}
+				}
+			}
+		}
+	}
+	if a.maxBodyBytes > 0 && req.Body != nil && req.Body != http.NoBody {
+		if req.ContentLength > a.maxBodyBytes {
+			return &StatusError{StatusCode: http.StatusRequestEntityTooLarge, Err: ErrBodyTooLarge}
+		}
+		if _, ok := req.Body.(*limitedBody); !ok {
+			req.Body = &limitedBody{ReadCloser: req.Body, remaining: a.maxBodyBytes}
+		}
+	}
+	return nil
+}
+
+//bodyLimitError body读取超过限制导致的绑定失败统一返回413
+func bodyLimitError(gctx *gin.Context, err error) error {
+	if body, ok := gctx.Request.Body.(*limitedBody); ok && body.exceeded {
+		return &StatusError{StatusCode: http.StatusRequestEntityTooLarge, Err: ErrBodyTooLarge}
+	}
+	return err
+}
+
+//parseMultipartForm 设置了WithMaxMultipartMemory时，在gin解析之前按照该限制解析multipart，流式读取时不解析
+func (a *argsInfo) parseMultipartForm(gctx *gin.Context) error {
+	if a.maxMultipartMemory <= 0 || a.streaming || gctx.Request.MultipartForm != nil {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(gctx.GetHeader("Content-Type"))
+	if mediaType != gin.MIMEMultipartPOSTForm {
+		return nil
+	}
+	return gctx.Request.ParseMultipartForm(a.maxMultipartMemory)
+}
+
+//checkSliceLen 在创建slice之前检查值的数量
+func (a *argsInfo) checkSliceLen(name string, count int) error {
+	if a.maxSliceLen > 0 && count > a.maxSliceLen {
+		return &StatusError{StatusCode: http.StatusBadRequest, Err: fmt.Errorf("%w: %s has %d values, limit %d", ErrSliceTooLong, name, count, a.maxSliceLen)}
+	}
+	return nil
+}
+
+//checkMapLen 检查map的key数量以及每个key上值的数量
+func (a *argsInfo) checkMapLen(name string, data map[string][]string) error {
+	if err := a.checkSliceLen(name, len(data)); err != nil {
+		return err
+	}
+	for k, v := range data {
+		if err := a.checkSliceLen(name+"["+k+"]", len(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//checkFormLen gin绑定结构体之前检查url以及form上每个key的值的数量
+func (a *argsInfo) checkFormLen(gctx *gin.Context) error {
+	if a.maxSliceLen <= 0 {
+		return nil
+	}
+	for k, v := range gctx.Request.URL.Query() {
+		if err := a.checkSliceLen(k, len(v)); err != nil {
+			return err
+		}
+	}
+	for k, v := range postFormValues(gctx) {
+		if err := a.checkSliceLen(k, len(v)); err != nil {
+			return err
+		}
+	}
+	if form := gctx.Request.MultipartForm; form != nil {
+		for k, v := range form.File {
+			if err := a.checkSliceLen(k, len(v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
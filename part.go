@@ -0,0 +1,70 @@
+package gbinding
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+//partLookup 按照名称取出multipart中part的内容
+type partLookup func(name string) ([]byte, bool, error)
+
+//multipartPart 从解析过的multipart中取出part，没有文件名的part在Value中，有文件名的在File中
+func multipartPart(gctx *gin.Context) partLookup {
+	return func(name string) ([]byte, bool, error) {
+		form, err := gctx.MultipartForm()
+		if err != nil {
+			if errors.Is(err, http.ErrNotMultipart) {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+		if values := form.Value[name]; len(values) > 0 {
+			return []byte(values[0]), true, nil
+		}
+		if files := form.File[name]; len(files) > 0 {
+			reader, err := files[0].Open()
+			if err != nil {
+				return nil, false, err
+			}
+			defer reader.Close()
+			data, err := ioutil.ReadAll(reader)
+			return data, err == nil, err
+		}
+		return nil, false, nil
+	}
+}
+
+//decodePart 使用和gin一致的json设置解码
+func decodePart(data []byte, obj interface{}) error {
+	return decodeJSON(bytes.NewReader(data), obj)
+}
+
+//bindPartFields 将带有part tag的字段按照json解码，part不存在时保持零值。
+//gin绑定form时可能按照嵌套字段的名称覆盖这些字段，所以需要在gin绑定之后解码
+func (a *argsInfo) bindPartFields(argInfo *argTypeInfo, elemValue reflect.Value, lookup partLookup) error {
+	for i := range argInfo.paramFields {
+		paramField := argInfo.paramFields[i]
+		if paramField.source != Part {
+			continue
+		}
+		data, ok, err := lookup(paramField.name)
+		if err != nil {
+			return err
+		}
+		filedValue := elemValue.FieldByIndex(paramField.index)
+		filedValue.Set(reflect.Zero(filedValue.Type()))
+		if !ok {
+			continue
+		}
+		if err := decodePart(data, filedValue.Addr().Interface()); err != nil {
+			return fmt.Errorf("part %s: %w", paramField.name, err)
+		}
+	}
+	return nil
+}
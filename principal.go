@@ -0,0 +1,135 @@
+package gbinding
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Principal 认证过的用户，WithRoles以及WithScopes通过它来检查权限
+type Principal interface {
+	Roles() []string
+	Scopes() []string
+}
+
+//AuthError 认证或者授权失败，StatusCode为401或者403，交给ResponseHandler处理
+type AuthError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("%s: %v", http.StatusText(e.StatusCode), e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+//principalKey 同一个请求中提取过的Principal缓存在gin.Context上
+const principalKey = "gbinding.principal"
+
+type principalExtractor struct {
+	principalType reflect.Type
+	extract       func(gctx *gin.Context) (Principal, reflect.Value, error)
+}
+
+//principal 通过RegisterPrincipal注册的提取器
+var principal *principalExtractor
+
+//RegisterPrincipal 注册Principal的提取器，handler的参数中有类型P时会注入当前用户，
+//提取失败时返回401的AuthError，提取器返回的AuthError会原样返回。必须在BindingAndInvoke之前调用
+func RegisterPrincipal[P Principal](extractor func(gctx *gin.Context) (P, error)) {
+	if extractor == nil {
+		log.Panic("principal extractor can't null")
+	}
+	principal = &principalExtractor{
+		principalType: reflect.TypeOf((*P)(nil)).Elem(),
+		extract: func(gctx *gin.Context) (Principal, reflect.Value, error) {
+			p, err := extractor(gctx)
+			if err != nil {
+				return nil, reflect.Value{}, err
+			}
+			return p, reflect.ValueOf(&p).Elem(), nil
+		},
+	}
+}
+
+type principalValue struct {
+	principal Principal
+	value     reflect.Value
+}
+
+//currentPrincipal 提取当前请求的Principal，同一个请求只提取一次
+func currentPrincipal(gctx *gin.Context) (Principal, reflect.Value, error) {
+	if cached, ok := gctx.Get(principalKey); ok {
+		p := cached.(principalValue)
+		return p.principal, p.value, nil
+	}
+	p, value, err := principal.extract(gctx)
+	if err != nil {
+		var authErr *AuthError
+		if errors.As(err, &authErr) {
+			return nil, reflect.Value{}, err
+		}
+		return nil, reflect.Value{}, &AuthError{StatusCode: http.StatusUnauthorized, Err: err}
+	}
+	gctx.Set(principalKey, principalValue{principal: p, value: value})
+	return p, value, nil
+}
+
+//authorize 检查WithRoles以及WithScopes，角色满足其中一个即可，scope需要全部满足
+func (c *callFunc) authorize(gctx *gin.Context) error {
+	if len(c.roles) == 0 && len(c.scopes) == 0 {
+		return nil
+	}
+	p, _, err := currentPrincipal(gctx)
+	if err != nil {
+		return err
+	}
+	if len(c.roles) > 0 && !containsAny(p.Roles(), c.roles) {
+		return &AuthError{StatusCode: http.StatusForbidden, Err: fmt.Errorf("require one of roles %v", c.roles)}
+	}
+	for i := range c.scopes {
+		if !contains(p.Scopes(), c.scopes[i]) {
+			return &AuthError{StatusCode: http.StatusForbidden, Err: fmt.Errorf("require scope %s", c.scopes[i])}
+		}
+	}
+	return nil
+}
+
+func contains(values []string, target string) bool {
+	for i := range values {
+		if values[i] == target {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(values []string, targets []string) bool {
+	for i := range targets {
+		if contains(values, targets[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+//WithRoles 调用函数前检查当前用户，满足其中一个角色即可，否则返回403的AuthError
+func WithRoles(roles ...string) CallOption {
+	return func(c *callFunc) {
+		c.roles = append(c.roles, roles...)
+	}
+}
+
+//WithScopes 调用函数前检查当前用户，需要满足全部的scope，否则返回403的AuthError
+func WithScopes(scopes ...string) CallOption {
+	return func(c *callFunc) {
+		c.scopes = append(c.scopes, scopes...)
+	}
+}
@@ -0,0 +1,233 @@
+package gbinding
+
+import (
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//QueryStyle url上参数的序列化方式，参照OpenAPI中query参数的style
+type QueryStyle string
+
+const (
+	//FormStyle explode时为 ids=1&ids=2，否则为 ids=1,2
+	FormStyle QueryStyle = "form"
+	//SpaceDelimitedStyle ids=1%202
+	SpaceDelimitedStyle QueryStyle = "spaceDelimited"
+	//PipeDelimitedStyle ids=1|2
+	PipeDelimitedStyle QueryStyle = "pipeDelimited"
+	//DeepObjectStyle filter[status]=open&filter[owner]=me，只能用于结构体或者map
+	DeepObjectStyle QueryStyle = "deepObject"
+)
+
+const (
+	styleTag   = "style"
+	explodeTag = "explode"
+)
+
+type queryStyle struct {
+	style   QueryStyle
+	explode bool
+}
+
+//delimiter 非explode时多个值之间的分隔符
+func (q queryStyle) delimiter() string {
+	switch q.style {
+	case SpaceDelimitedStyle:
+		return " "
+	case PipeDelimitedStyle:
+		return "|"
+	default:
+		return ","
+	}
+}
+
+//split 非explode的情况下按照分隔符拆分成多个值
+func (q queryStyle) split(values []string) []string {
+	if q.explode || q.style == DeepObjectStyle {
+		return values
+	}
+	result := make([]string, 0, len(values))
+	for i := range values {
+		if values[i] == "" {
+			continue
+		}
+		result = append(result, strings.Split(values[i], q.delimiter())...)
+	}
+	return result
+}
+
+func checkQueryStyle(style QueryStyle) {
+	switch style {
+	case FormStyle, SpaceDelimitedStyle, PipeDelimitedStyle, DeepObjectStyle:
+	default:
+		log.Panicf("unsupport query style %s", style)
+	}
+}
+
+//defaultExplode 没有指定explode时，和OpenAPI一致，form和deepObject默认explode
+func defaultExplode(style QueryStyle) bool {
+	return style == FormStyle || style == DeepObjectStyle
+}
+
+//structQueryStyle 结构体中按style绑定的字段
+type structQueryStyle struct {
+	key   string
+	index []int
+	queryStyle
+}
+
+//formKey 字段在url上对应的名称,和gin一致优先使用form tag
+func formKey(field reflect.StructField) string {
+	if name := tagName(field, "form"); name != "" {
+		return name
+	}
+	return field.Name
+}
+
+//fieldQueryStyle 字段上的style，tag优先，其次是WithQueryStyle设置的名称，最后是WithQueryStyle设置的默认值(只对slice生效)
+func (a *argsInfo) fieldQueryStyle(field reflect.StructField, key string) (queryStyle, bool) {
+	if tagStyle, ok := field.Tag.Lookup(styleTag); ok {
+		result := queryStyle{style: QueryStyle(tagStyle), explode: defaultExplode(QueryStyle(tagStyle))}
+		checkQueryStyle(result.style)
+		if tagExplode, ok := field.Tag.Lookup(explodeTag); ok {
+			explode, err := strconv.ParseBool(tagExplode)
+			if err != nil {
+				log.Panicf("field:%s explode tag expect bool but get %s", field.Name, tagExplode)
+			}
+			result.explode = explode
+		}
+		return result, true
+	}
+	if result, ok := a.queryStyles[key]; ok {
+		return result, true
+	}
+	if result, ok := a.queryStyles[""]; ok && field.Type.Kind() == reflect.Slice {
+		return result, true
+	}
+	return queryStyle{}, false
+}
+
+//structQueryStyles 检查结构体中设置了style的字段，保存在结构体参数上，绑定时使用
+func (a *argsInfo) structQueryStyles(structType reflect.Type) []structQueryStyle {
+	var styles []structQueryStyle
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		key := formKey(field)
+		style, ok := a.fieldQueryStyle(field, key)
+		if !ok {
+			continue
+		}
+		if style.style == DeepObjectStyle {
+			if field.Type.Kind() != reflect.Struct && !isBasicMapType(field.Type) {
+				log.Panicf("struct:%s field:%s deepObject style expect struct or map but get %s", structType.String(), field.Name, field.Type.String())
+			}
+		} else if field.Type.Kind() != reflect.Slice {
+			log.Panicf("struct:%s field:%s %s style expect slice but get %s", structType.String(), field.Name, style.style, field.Type.String())
+		}
+		styles = append(styles, structQueryStyle{key: key, index: field.Index, queryStyle: style})
+	}
+	return styles
+}
+
+//basicSliceStyle 绑定slice参数时使用的style
+func (a *argsInfo) basicSliceStyle() queryStyle {
+	if result, ok := a.queryStyles[a.queryName]; ok {
+		return result
+	}
+	if result, ok := a.queryStyles[""]; ok {
+		return result
+	}
+	return queryStyle{style: FormStyle, explode: true}
+}
+
+//normalizeQuery 将非explode的参数在url上展开成重复key的形式，这样gin绑定结构体时可以直接绑定到slice上
+func (a *argsInfo) normalizeQuery(gctx *gin.Context, argInfo *argTypeInfo) {
+	if len(argInfo.styles) == 0 {
+		return
+	}
+	req := gctx.Request
+	query := req.URL.Query()
+	changed := false
+	for i := range argInfo.styles {
+		style := argInfo.styles[i]
+		values, ok := query[style.key]
+		if !ok || style.explode || style.style == DeepObjectStyle {
+			continue
+		}
+		query[style.key] = style.split(values)
+		changed = true
+	}
+	if changed {
+		req.URL.RawQuery = query.Encode()
+		//让gin重新解析form
+		req.Form = nil
+	}
+}
+
+//bindDeepObjects 按照 key[field]=value 的形式绑定deepObject的字段
+func (a *argsInfo) bindDeepObjects(gctx *gin.Context, argInfo *argTypeInfo, elemValue reflect.Value) error {
+	for i := range argInfo.styles {
+		style := argInfo.styles[i]
+		if style.style != DeepObjectStyle {
+			continue
+		}
+		data := bracketValues(gctx.Request.URL.Query(), style.key)
+		fieldValue := elemValue.FieldByIndex(style.index)
+		if fieldValue.Kind() == reflect.Map {
+			if err := a.setMapValue(fieldValue, style.key, data); err != nil {
+				return err
+			}
+			continue
+		}
+		//gin可能已经按照字段名绑定过了，以deepObject为准
+		fieldValue.Set(reflect.Zero(fieldValue.Type()))
+		if err := a.setDeepObject(fieldValue, style.key, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *argsInfo) setDeepObject(structValue reflect.Value, name string, data map[string][]string) error {
+	structType := structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		key := formKey(field)
+		var values []string
+		for k, v := range data {
+			if a.filedNameIsEqual(key, k) {
+				values = v
+				break
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		fieldValue := structValue.Field(i)
+		if field.Type.Kind() == reflect.Slice {
+			if err := a.checkSliceLen(name+"["+key+"]", len(values)); err != nil {
+				return err
+			}
+			slice := reflect.MakeSlice(field.Type, len(values), len(values))
+			if err := setBasicSlice(slice, field.Type.Elem().Kind(), values); err != nil {
+				return err
+			}
+			fieldValue.Set(slice)
+			continue
+		}
+		if err := setBasicValue(fieldValue, values[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package gbinding
 
 import (
+	"fmt"
 	"log"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -27,3 +29,17 @@ func (r *response) Return(ctx *gin.Context, data interface{}, err error) {
 		responseFn(ctx, data, err)
 	}
 }
+
+//StatusError 绑定过程中产生的带有http状态码的错误，例如body过大时的413，交给ResponseHandler处理
+type StatusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %v", http.StatusText(e.StatusCode), e.Err)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,183 @@
+package gbinding
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+//sanitizeTag 结构体字符串字段上的转换，多个使用逗号分隔，按照顺序执行，例如 `sanitize:"trim,lower"`。
+//全部来源绑定完之后、binding以及validate tag校验之前执行
+const sanitizeTag = "sanitize"
+
+//sanitizer 对字符串的转换
+type sanitizer func(string) string
+
+//sanitizers 通过名称使用的转换，unicode-normalize需要参数，单独处理
+var sanitizers = map[string]sanitizer{
+	"trim":           strings.TrimSpace,
+	"lower":          strings.ToLower,
+	"upper":          strings.ToUpper,
+	"collapse-space": collapseSpace,
+	"strip-html":     stripHTML,
+}
+
+//normForms unicode-normalize支持的参数
+var normForms = map[string]norm.Form{
+	"NFC":  norm.NFC,
+	"NFD":  norm.NFD,
+	"NFKC": norm.NFKC,
+	"NFKD": norm.NFKD,
+}
+
+//RegisterSanitizer 注册sanitize tag以及WithSanitizers上使用的转换，和已有的名称相同时覆盖
+func RegisterSanitizer(name string, fn func(string) string) {
+	if name == "" || strings.ContainsAny(name, ",:") {
+		log.Panicf("sanitizer name %q can't be empty or contain , and :", name)
+	}
+	sanitizers[name] = fn
+}
+
+//WithSanitizers basic参数以及basic slice参数中字符串的转换，结构体字段使用sanitize tag
+func WithSanitizers(names ...string) CallOption {
+	fns, err := parseSanitizers(strings.Join(names, ","))
+	if err != nil {
+		log.Panicf("WithSanitizers %v", err)
+	}
+	return func(c *callFunc) {
+		c.asInfo.sanitizers = fns
+	}
+}
+
+//parseSanitizers 解析逗号分隔的名称，unicode-normalize的参数使用冒号，例如 unicode-normalize:NFC
+func parseSanitizers(value string) ([]sanitizer, error) {
+	var fns []sanitizer
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if strings.HasPrefix(name, "unicode-normalize:") {
+			form := strings.TrimPrefix(name, "unicode-normalize:")
+			f, ok := normForms[strings.ToUpper(form)]
+			if !ok {
+				return nil, fmt.Errorf("unicode-normalize expect one of NFC,NFD,NFKC,NFKD but get %s", form)
+			}
+			fns = append(fns, f.String)
+			continue
+		}
+		fn, ok := sanitizers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown sanitizer %s", name)
+		}
+		fns = append(fns, fn)
+	}
+	return fns, nil
+}
+
+//sanitizeField 需要转换的字段，nested为嵌套结构体中需要转换的字段
+type sanitizeField struct {
+	index  []int
+	fns    []sanitizer
+	nested []sanitizeField
+}
+
+//structSanitizers 收集结构体上带有sanitize tag的字段
+func structSanitizers(structType reflect.Type) []sanitizeField {
+	return walkStructFields(structType, func(owner reflect.Type, field reflect.StructField, nested []sanitizeField) (sanitizeField, bool) {
+		result := sanitizeField{index: field.Index, nested: nested}
+		if tag, ok := field.Tag.Lookup(sanitizeTag); ok {
+			if elemKind(field.Type) != reflect.String {
+				log.Panicf("struct:%s field:%s sanitize tag only support string field but get %s", owner.String(), field.Name, field.Type.String())
+			}
+			fns, err := parseSanitizers(tag)
+			if err != nil {
+				log.Panicf("struct:%s field:%s sanitize tag %v", owner.String(), field.Name, err)
+			}
+			result.fns = fns
+		}
+		return result, len(result.fns) > 0 || len(result.nested) > 0
+	})
+}
+
+//elemType 去掉指针以及slice之后的类型
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t
+}
+
+func elemKind(t reflect.Type) reflect.Kind {
+	return elemType(t).Kind()
+}
+
+//sanitizeStruct 转换结构体上的字段
+func sanitizeStruct(elemValue reflect.Value, fields []sanitizeField) {
+	for i := range fields {
+		sanitizeValue(elemValue.FieldByIndex(fields[i].index), &fields[i])
+	}
+}
+
+func sanitizeValue(value reflect.Value, field *sanitizeField) {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(applySanitizers(field.fns, value.String()))
+	case reflect.Ptr:
+		if !value.IsNil() {
+			sanitizeValue(value.Elem(), field)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			sanitizeValue(value.Index(i), field)
+		}
+	case reflect.Struct:
+		sanitizeStruct(value, field.nested)
+	}
+}
+
+func applySanitizers(fns []sanitizer, value string) string {
+	for _, fn := range fns {
+		value = fn(value)
+	}
+	return value
+}
+
+//sanitizeBasic 通过WithSanitizers设置时转换basic参数以及basic slice参数中的字符串
+func (a *argsInfo) sanitizeBasic(value reflect.Value) {
+	if len(a.sanitizers) == 0 {
+		return
+	}
+	sanitizeValue(value, &sanitizeField{fns: a.sanitizers})
+}
+
+//collapseSpace 连续的空白字符替换成一个空格，同时去掉首尾的空白
+func collapseSpace(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+//stripHTML 去掉html标签以及注释，保留标签之间的文本，不会转换实体。<后面不是字母,/,!,?时作为普通文本
+func stripHTML(value string) string {
+	var builder strings.Builder
+	builder.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '<' || i+1 == len(value) || !isTagStart(value[i+1]) {
+			builder.WriteByte(value[i])
+			continue
+		}
+		end := strings.IndexByte(value[i:], '>')
+		if end < 0 {
+			//没有闭合的标签丢弃剩余部分
+			break
+		}
+		i += end
+	}
+	return builder.String()
+}
+
+func isTagStart(c byte) bool {
+	return c == '/' || c == '!' || c == '?' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
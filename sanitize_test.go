@@ -0,0 +1,115 @@
+package gbinding
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_stripHTML(t *testing.T) {
+	assert.Equal(t, stripHTML("<b>bold</b> text<br/>"), "bold text")
+	assert.Equal(t, stripHTML("<!-- note -->a<script>x</script>"), "ax")
+	assert.Equal(t, stripHTML("1 < 2 and 3 > 2"), "1 < 2 and 3 > 2")
+	assert.Equal(t, stripHTML("a<img src=x"), "a")
+}
+
+func Test_parseSanitizers(t *testing.T) {
+	fns, err := parseSanitizers("trim, collapse-space,upper")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, applySanitizers(fns, "  hello \t world "), "HELLO WORLD")
+
+	fns, err = parseSanitizers("unicode-normalize:NFC")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, applySanitizers(fns, "e\u0301"), "\u00e9")
+
+	_, err = parseSanitizers("unicode-normalize:ABC")
+	assert.NotEqual(t, err, nil)
+	_, err = parseSanitizers("reverse")
+	assert.NotEqual(t, err, nil)
+}
+
+func TestBindingAndInvoke_sanitize(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+	type profile struct {
+		Bio string `json:"bio" sanitize:"strip-html,trim"`
+	}
+	type signup struct {
+		Email    string    `json:"email" sanitize:"trim,lower" validate:"email"`
+		Name     string    `json:"name" sanitize:"collapse-space"`
+		Tags     []string  `json:"tags" sanitize:"trim,upper"`
+		Region   string    `header:"X-Region" sanitize:"trim,upper"`
+		Profile  *profile  `json:"profile"`
+		Profiles []profile `json:"profiles"`
+		Password string    `json:"password"`
+	}
+
+	var got signup
+	handler := BindingAndInvoke(func(s signup) error {
+		got = s
+		return nil
+	})
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{
+		"email":" Bob@Example.COM ",
+		"name":"  Bob   Smith ",
+		"tags":[" a ","b"],
+		"profile":{"bio":" <b>hi</b> "},
+		"profiles":[{"bio":"<i>x</i>"}],
+		"password":" secret "
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Region", " cn ")
+	serve(http.MethodPost, "/", handler, req)
+	//先转换再校验
+	assert.Equal(t, lastErr, nil)
+	assert.Equal(t, got.Email, "bob@example.com")
+	assert.Equal(t, got.Name, "Bob Smith")
+	assert.Equal(t, got.Tags, []string{"A", "B"})
+	assert.Equal(t, got.Region, "CN")
+	assert.Equal(t, got.Profile.Bio, "hi")
+	assert.Equal(t, got.Profiles[0].Bio, "x")
+	assert.Equal(t, got.Password, " secret ")
+
+	//binding tag同样在转换之后校验
+	t.Run("binding tag", func(t *testing.T) {
+		type query struct {
+			Name string `form:"name" binding:"max=3" sanitize:"trim"`
+		}
+		var got query
+		handler := BindingAndInvoke(func(q query) error {
+			got = q
+			return nil
+		})
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?name=%20%20ab%20%20", nil))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got.Name, "ab")
+	})
+
+	t.Run("basic", func(t *testing.T) {
+		var keyword string
+		handler := BindingAndInvoke(func(q string) error {
+			keyword = q
+			return nil
+		}, WithQueryName("q"), WithSanitizers("trim", "lower"))
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?q=%20Gin%20", nil))
+		assert.Equal(t, keyword, "gin")
+	})
+
+	t.Run("invalid tag", func(t *testing.T) {
+		type request struct {
+			Age int `form:"age" sanitize:"trim"`
+		}
+		defer func() {
+			assert.Equal(t, recover().(string), "struct:gbinding.request field:Age sanitize tag only support string field but get int")
+		}()
+		BindingAndInvoke(func(r request) error {
+			return nil
+		})
+	})
+}
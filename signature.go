@@ -0,0 +1,141 @@
+package gbinding
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//签名校验失败的原因，都会包装在401的AuthError中，可以通过errors.Is判断
+var (
+	ErrSignatureMissing = errors.New("signature: missing")
+	ErrSignatureInvalid = errors.New("signature: invalid")
+	ErrSignatureExpired = errors.New("signature: timestamp outside tolerance")
+)
+
+//SignatureOption 签名校验的选项
+type SignatureOption func(c *signatureConfig)
+
+type signatureConfig struct {
+	header string
+	secret []byte
+	algo   func() hash.Hash
+	//prefix 签名前面的前缀，例如 X-Hub-Signature-256 的 sha256=
+	prefix string
+	//timestamped Stripe风格的签名，header为 t=时间戳,v1=签名，签名内容为 时间戳.body
+	timestamped bool
+	tolerance   time.Duration
+	now         func() time.Time
+}
+
+//WithHMACSignature 调用函数前读取完整的body，使用secret以及algo(例如sha256.New)校验header上的hex签名，
+//body会被缓存，后面的绑定可以继续读取。校验失败时返回401的AuthError
+func WithHMACSignature(header string, secret []byte, algo func() hash.Hash, opts ...SignatureOption) CallOption {
+	if header == "" || len(secret) == 0 || algo == nil {
+		log.Panic("hmac signature header,secret and algo can't empty")
+	}
+	config := &signatureConfig{
+		header: header,
+		secret: secret,
+		algo:   algo,
+		now:    time.Now,
+	}
+	for i := range opts {
+		opts[i](config)
+	}
+	return func(c *callFunc) {
+		c.signature = config
+	}
+}
+
+//SignaturePrefix 签名前面的前缀，例如GitHub的 sha256=
+func SignaturePrefix(prefix string) SignatureOption {
+	return func(c *signatureConfig) {
+		c.prefix = prefix
+	}
+}
+
+//SignatureTimestamp 使用Stripe风格的带时间戳的签名，时间戳和当前时间相差超过tolerance时拒绝，防止重放
+func SignatureTimestamp(tolerance time.Duration) SignatureOption {
+	return func(c *signatureConfig) {
+		c.timestamped = true
+		c.tolerance = tolerance
+	}
+}
+
+//SignatureNow 设置校验时间戳时使用的当前时间，一般用于测试
+func SignatureNow(now func() time.Time) SignatureOption {
+	return func(c *signatureConfig) {
+		c.now = now
+	}
+}
+
+//verify 读取并缓存body后校验签名，limit为WithMaxBodyBytes的限制
+func (c *signatureConfig) verify(gctx *gin.Context, limit int64) error {
+	body, err := cacheBody(gctx, limit)
+	if err != nil {
+		return err
+	}
+	value := gctx.GetHeader(c.header)
+	if value == "" {
+		return &AuthError{StatusCode: http.StatusUnauthorized, Err: ErrSignatureMissing}
+	}
+	if !c.timestamped {
+		if !c.equal(body, strings.TrimPrefix(value, c.prefix)) {
+			return &AuthError{StatusCode: http.StatusUnauthorized, Err: ErrSignatureInvalid}
+		}
+		return nil
+	}
+
+	var (
+		timestamp  string
+		signatures []string
+	)
+	for _, item := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return &AuthError{StatusCode: http.StatusUnauthorized, Err: ErrSignatureMissing}
+	}
+	diff := c.now().Sub(time.Unix(unix, 0))
+	if diff > c.tolerance || diff < -c.tolerance {
+		return &AuthError{StatusCode: http.StatusUnauthorized, Err: ErrSignatureExpired}
+	}
+	payload := append([]byte(timestamp+"."), body...)
+	//密钥轮换期间可能有多个签名，满足一个即可
+	for i := range signatures {
+		if c.equal(payload, signatures[i]) {
+			return nil
+		}
+	}
+	return &AuthError{StatusCode: http.StatusUnauthorized, Err: ErrSignatureInvalid}
+}
+
+//equal 常量时间比较签名
+func (c *signatureConfig) equal(payload []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(c.algo, c.secret)
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
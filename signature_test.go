@@ -0,0 +1,75 @@
+package gbinding
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func hmacHex(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestBindingAndInvoke_hmacSignature(t *testing.T) {
+	var lastErr error
+	var lastData interface{}
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastData, lastErr = data, err
+	})
+	type event struct {
+		Action string `json:"action"`
+	}
+	body := `{"action":"opened"}`
+	newReq := func(header, signature string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(header, signature)
+		return req
+	}
+
+	t.Run("github", func(t *testing.T) {
+		handler := BindingAndInvoke(func(e event) (string, error) {
+			return e.Action, nil
+		}, WithHMACSignature("X-Hub-Signature-256", []byte("secret"), sha256.New, SignaturePrefix("sha256=")))
+
+		serve(http.MethodPost, "/", handler, newReq("X-Hub-Signature-256", "sha256="+hmacHex("secret", body)))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, "opened")
+
+		serve(http.MethodPost, "/", handler, newReq("X-Hub-Signature-256", "sha256="+hmacHex("other", body)))
+		assert.Equal(t, errors.Is(lastErr, ErrSignatureInvalid), true)
+	})
+
+	t.Run("timestamped", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		handler := BindingAndInvoke(func(e event) (string, error) {
+			return e.Action, nil
+		}, WithHMACSignature("Stripe-Signature", []byte("secret"), sha256.New,
+			SignatureTimestamp(5*time.Minute), SignatureNow(func() time.Time { return now })))
+		sign := func(ts int64) string {
+			return fmt.Sprintf("t=%d,v1=%s,v1=%s", ts, hmacHex("old", "x"), hmacHex("secret", fmt.Sprintf("%d.%s", ts, body)))
+		}
+
+		serve(http.MethodPost, "/", handler, newReq("Stripe-Signature", sign(900)))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, lastData, "opened")
+
+		serve(http.MethodPost, "/", handler, newReq("Stripe-Signature", sign(600)))
+		assert.Equal(t, errors.Is(lastErr, ErrSignatureExpired), true)
+
+		serve(http.MethodPost, "/", handler, newReq("Stripe-Signature", "t=1000"))
+		assert.Equal(t, errors.Is(lastErr, ErrSignatureMissing), true)
+	})
+}
@@ -0,0 +1,163 @@
+package gbinding
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+//Source 绑定basic参数时数据的来源
+type Source string
+
+const (
+	Query    Source = "query"
+	PostForm Source = "postForm"
+	Path     Source = "path"
+	Header   Source = "header"
+	Cookie   Source = "cookie"
+	//Context gin.Context的key或者context.Context的value
+	Context Source = "context"
+	//Auth Authorization中的凭证以及api key，只能通过结构体字段上的auth tag使用
+	Auth Source = "auth"
+	//Claim 通过WithJWT校验过的claims，只能通过结构体字段上的claim tag使用
+	Claim Source = "claim"
+	//File multipart上的文件，类型为*multipart.FileHeader以及[]*multipart.FileHeader的字段按照form tag绑定
+	File Source = "file"
+	//Part multipart中的part，带有part tag的字段按照json解码
+	Part Source = "part"
+	//Body 由gin从body绑定的字段，只用于FieldError
+	Body Source = "body"
+)
+
+//defaultSources 没有设置WithSources时的查找顺序
+var defaultSources = []Source{Query, PostForm, Path, Header, Cookie, Context}
+
+//sourceName 该来源上设置的名称，没有设置时返回空
+func (a *argsInfo) sourceName(source Source) string {
+	switch source {
+	case Query, PostForm:
+		return a.queryName
+	case Path:
+		if len(a.pathNames) > 0 {
+			return a.pathNames[0]
+		}
+	case Header:
+		if len(a.headerNames) > 0 {
+			return a.headerNames[0]
+		}
+	case Cookie:
+		if len(a.cookieNames) > 0 {
+			return a.cookieNames[0]
+		}
+	case Context:
+		if len(a.contextKeys) > 0 {
+			return a.contextKeys[0]
+		}
+	default:
+		log.Panicf("unsupport source %s", source)
+	}
+	return ""
+}
+
+//basicName basic参数按照来源顺序第一个设置了的名称，用于错误信息
+func (a *argsInfo) basicName() string {
+	sources := a.sources
+	if sources == nil {
+		sources = defaultSources
+	}
+	for i := range sources {
+		if name := a.sourceName(sources[i]); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+//missingBasic 所有来源上都没有取到basic参数
+func (a *argsInfo) missingBasic(argInfo *argTypeInfo) error {
+	return &BindError{
+		Kind:  KindMissing,
+		Field: a.basicName(),
+		Type:  argInfo.argType.String(),
+		Err:   fmt.Errorf("try to binding %s,but can't get from url,uri,header,cookie. please check you option name set", argInfo.argType.String()),
+	}
+}
+
+//checkSources 使用WithSources时，每个来源都必须设置了对应的名称
+func (a *argsInfo) checkSources() {
+	if a.sources == nil {
+		return
+	}
+	if len(a.sources) == 0 {
+		log.Panicf("WithSources must set at least one source")
+	}
+	for i := range a.sources {
+		if a.sourceName(a.sources[i]) == "" {
+			log.Panicf("source %s has no name, please check you option name set", a.sources[i])
+		}
+	}
+}
+
+//lookupSource 从指定的来源上取值，query和postForm允许空值，其他来源空值视为不存在
+func (a *argsInfo) lookupSource(gctx *gin.Context, source Source) (string, bool) {
+	name := a.sourceName(source)
+	if name == "" {
+		return "", false
+	}
+	var data string
+	switch source {
+	case Query:
+		return gctx.GetQuery(name)
+	case PostForm:
+		return gctx.GetPostForm(name)
+	case Path:
+		data = gctx.Param(name)
+	case Header:
+		data = gctx.GetHeader(name)
+	case Cookie:
+		data, _ = gctx.Cookie(name)
+	case Context:
+		if value, ok := contextValue(gctx, name); ok {
+			data, _ = cast.ToStringE(value)
+		}
+	}
+	return data, data != ""
+}
+
+//lookupBasic 按照来源的顺序取第一个存在的值，strictSources时所有来源的值必须一致
+func (a *argsInfo) lookupBasic(gctx *gin.Context) (string, bool, error) {
+	sources := a.sources
+	if sources == nil {
+		sources = defaultSources
+	}
+	var (
+		data       string
+		exist      bool
+		dataSource Source
+	)
+	for i := range sources {
+		value, ok := a.lookupSource(gctx, sources[i])
+		if !ok {
+			continue
+		}
+		if !exist {
+			data, exist, dataSource = value, true, sources[i]
+			if !a.strictSources {
+				break
+			}
+			continue
+		}
+		if value != data {
+			return "", false, &StatusError{StatusCode: http.StatusBadRequest, Err: &BindError{
+				Kind:  KindConflict,
+				Field: a.basicName(),
+				Value: fmt.Sprintf("%s=%q, %s=%q", dataSource, data, sources[i], value),
+				Err:   fmt.Errorf("param conflict, %s get %q but %s get %q", dataSource, data, sources[i], value),
+			}}
+		}
+	}
+	return data, exist, nil
+}
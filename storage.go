@@ -0,0 +1,361 @@
+package gbinding
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//ErrNoStorage 没有通过WithStorage设置存储时调用UploadStream.Store
+var ErrNoStorage = errors.New("upload storage not set, please use WithStorage")
+
+var storedFileType = reflect.TypeOf(&StoredFile{})
+var storedFilesType = reflect.TypeOf([]*StoredFile{})
+var uploadStreamType = reflect.TypeOf(&UploadStream{})
+
+//defaultMultipartMemory 和gin的默认值一致，流式读取时普通字段的最大字节数
+const defaultMultipartMemory = 32 << 20
+
+//Storage 流式上传的文件的存储，Save需要读取完r，读取出错时需要清理已经写入的数据
+type Storage interface {
+	//Save 保存文件，返回之后可以定位该文件的key，file中Size以及SHA256在读取完成后才会设置
+	Save(ctx context.Context, file *StoredFile, r io.Reader) (string, error)
+	//Delete 删除已经保存的文件，绑定失败时用于清理
+	Delete(ctx context.Context, key string) error
+}
+
+//StoredFile 流式保存到Storage之后的文件，可以作为结构体字段代替*multipart.FileHeader，同一个结构体上不能同时使用两者
+type StoredFile struct {
+	//Field form上的名称
+	Field string
+	//Filename 客户端提供的文件名
+	Filename string
+	//ContentType 通过文件内容检测到的MIME类型
+	ContentType string
+	Size        int64
+	//SHA256 文件内容的hex编码的sha256
+	SHA256 string
+	//Key Storage返回的key
+	Key string
+}
+
+//LocalStorage 保存到本地目录的Storage，key为文件的路径
+type LocalStorage struct {
+	dir string
+}
+
+//NewLocalStorage 保存到dir目录，目录需要已经存在
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{dir: filepath.Clean(dir)}
+}
+
+func (s *LocalStorage) Save(ctx context.Context, file *StoredFile, r io.Reader) (string, error) {
+	//文件名由客户端提供，只保留扩展名
+	f, err := ioutil.TempFile(s.dir, "upload-*"+strings.ToLower(filepath.Ext(filepath.Base(file.Filename))))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	if filepath.Dir(filepath.Clean(key)) != s.dir {
+		return fmt.Errorf("key %s not in %s", key, s.dir)
+	}
+	return os.Remove(key)
+}
+
+//WithStorage 设置流式上传使用的Storage，结构体上有*StoredFile字段时必须设置
+func WithStorage(storage Storage) CallOption {
+	return func(c *callFunc) {
+		c.asInfo.storage = storage
+	}
+}
+
+//isStoredFileField 字段类型是*StoredFile或者[]*StoredFile
+func isStoredFileField(fieldType reflect.Type) bool {
+	return fieldType == storedFileType || fieldType == storedFilesType
+}
+
+//UploadStream 按照到达的顺序读取multipart的part，不会缓存到内存或者临时文件
+type UploadStream struct {
+	ctx    context.Context
+	reader *multipart.Reader
+	args   *argsInfo
+}
+
+//Next 返回下一个part，没有时返回io.EOF，调用Next时上一个part没有读取的部分会被丢弃
+func (s *UploadStream) Next() (*multipart.Part, error) {
+	return s.reader.NextPart()
+}
+
+//Store 将part保存到WithStorage设置的Storage，按照WithFileConstraint检查，不满足时返回400的StatusError
+func (s *UploadStream) Store(part *multipart.Part) (*StoredFile, error) {
+	if s.args.storage == nil {
+		return nil, ErrNoStorage
+	}
+	file, fieldErrs, err := s.args.storePart(s.ctx, part, s.args.fileConstraint(part.FormName(), nil))
+	if err != nil {
+		return nil, err
+	}
+	if err := fieldErrorsResult(fieldErrs); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+//bindUploadStream 绑定*UploadStream参数
+func (a *argsInfo) bindUploadStream(gctx *gin.Context) (reflect.Value, error) {
+	rewindBody(gctx)
+	reader, err := gctx.Request.MultipartReader()
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(&UploadStream{ctx: gctx, reader: reader, args: a}), nil
+}
+
+//sizeReader 计算读取的字节数，超过limit时返回错误
+type sizeReader struct {
+	reader   io.Reader
+	size     int64
+	limit    int64
+	exceeded bool
+}
+
+func (r *sizeReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.size += int64(n)
+	if r.limit > 0 && r.size > r.limit {
+		r.exceeded = true
+		return n, fmt.Errorf("file size exceeds %d", r.limit)
+	}
+	return n, err
+}
+
+//storePart 检查约束并保存一个part，同时计算大小以及sha256
+func (a *argsInfo) storePart(ctx context.Context, part *multipart.Part, constraint FileConstraint) (*StoredFile, FieldErrors, error) {
+	name, filename := part.FormName(), part.FileName()
+	if len(constraint.Extensions) > 0 && !acceptExtension(constraint.Extensions, filename) {
+		return nil, FieldErrors{{Field: name, Source: File, Rule: "ext", Param: strings.Join(constraint.Extensions, ","), Value: filename}}, nil
+	}
+	buffered := bufio.NewReaderSize(part, 512)
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, nil, err
+	}
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(constraint.Accept) > 0 && !acceptContentType(constraint.Accept, contentType) {
+		return nil, FieldErrors{{Field: name, Source: File, Rule: "accept", Param: strings.Join(constraint.Accept, ","), Value: contentType}}, nil
+	}
+
+	file := &StoredFile{Field: name, Filename: filename, ContentType: contentType}
+	hash := sha256.New()
+	reader := &sizeReader{reader: io.TeeReader(buffered, hash), limit: constraint.MaxSize}
+	key, err := a.storage.Save(ctx, file, reader)
+	if reader.exceeded {
+		return nil, FieldErrors{{Field: name, Source: File, Rule: "maxSize", Param: strconv.FormatInt(constraint.MaxSize, 10), Value: filename}}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	file.Key = key
+	file.Size = reader.size
+	file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return file, nil, nil
+}
+
+//fileFieldName 去掉 name[] 以及 name[0] 中的下标
+func fileFieldName(key string) string {
+	if i := strings.IndexByte(key, '['); i > 0 && strings.HasSuffix(key, "]") {
+		if index := key[i+1 : len(key)-1]; index == "" || isDigits(index) {
+			return key[:i]
+		}
+	}
+	return key
+}
+
+func isDigits(s string) bool {
+	for i := range s {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//storedKeysKey 绑定过程中保存到Storage的文件的key，保存在gin.Context上，绑定失败时删除
+const storedKeysKey = "gbinding.storedKeys"
+
+//rememberStored 记录绑定过程中保存的文件
+func rememberStored(gctx *gin.Context, key string) {
+	keys, _ := gctx.Get(storedKeysKey)
+	stored, _ := keys.([]string)
+	gctx.Set(storedKeysKey, append(stored, key))
+}
+
+//deleteStored 绑定失败时删除本次绑定已经保存的文件，之后的校验、其他字段或者其他参数失败时也需要删除
+func (a *argsInfo) deleteStored(gctx *gin.Context) {
+	keys, ok := gctx.Get(storedKeysKey)
+	if !ok || a.storage == nil {
+		return
+	}
+	for _, key := range keys.([]string) {
+		_ = a.storage.Delete(gctx, key)
+	}
+	gctx.Set(storedKeysKey, []string(nil))
+}
+
+//bindStream 流式读取multipart，*StoredFile字段上的文件保存到Storage，其他字段按照form tag绑定，
+//保存的文件通过rememberStored记录，绑定失败时由invoke删除
+func (a *argsInfo) bindStream(gctx *gin.Context, argInfo *argTypeInfo, elemValuePrt reflect.Value) error {
+	rewindBody(gctx)
+	reader, err := gctx.Request.MultipartReader()
+	if err != nil {
+		return err
+	}
+	streamFields := make(map[string]paramField)
+	partNames := make(map[string]bool)
+	for _, field := range argInfo.paramFields {
+		if field.stream {
+			streamFields[fileFieldName(field.name)] = field
+		}
+		if field.source == Part {
+			partNames[field.name] = true
+		}
+	}
+	files := make(map[string][]*StoredFile)
+
+	maxMemory := a.maxMultipartMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultMultipartMemory
+	}
+	var (
+		form      = url.Values{}
+		parts     = make(map[string][]byte)
+		counts    = make(map[string]int)
+		fieldErrs FieldErrors
+	)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := part.FormName()
+		//part tag的字段不管有没有文件名都读取到内存中
+		if part.FileName() == "" || partNames[name] {
+			data, err := ioutil.ReadAll(io.LimitReader(part, maxMemory+1))
+			if err != nil {
+				return err
+			}
+			if maxMemory -= int64(len(data)); maxMemory < 0 {
+				return &StatusError{StatusCode: http.StatusRequestEntityTooLarge, Err: multipart.ErrMessageTooLarge}
+			}
+			if partNames[name] {
+				if _, ok := parts[name]; !ok {
+					parts[name] = data
+				}
+				continue
+			}
+			form.Add(name, string(data))
+			continue
+		}
+		field, ok := streamFields[fileFieldName(name)]
+		if !ok {
+			continue
+		}
+		counts[field.name]++
+		if err := a.checkSliceLen(field.name, counts[field.name]); err != nil {
+			return err
+		}
+		constraint := a.fileConstraint(field.name, field.fileConstraint)
+		if constraint.MaxFiles > 0 && counts[field.name] > constraint.MaxFiles {
+			continue
+		}
+		file, errs, err := a.storePart(gctx, part, constraint)
+		if err != nil {
+			return err
+		}
+		if len(errs) > 0 {
+			fieldErrs = append(fieldErrs, errs...)
+			continue
+		}
+		files[field.name] = append(files[field.name], file)
+		rememberStored(gctx, file.Key)
+	}
+	for _, field := range argInfo.paramFields {
+		if !field.stream {
+			continue
+		}
+		constraint := a.fileConstraint(field.name, field.fileConstraint)
+		if constraint.MaxFiles > 0 && counts[field.name] > constraint.MaxFiles {
+			fieldErrs = append(fieldErrs, &FieldError{Field: field.name, Source: File, Rule: "maxFiles", Param: strconv.Itoa(constraint.MaxFiles), Value: strconv.Itoa(counts[field.name])})
+		}
+	}
+	if err := fieldErrorsResult(fieldErrs); err != nil {
+		return err
+	}
+
+	elemValue := elemValuePrt.Elem()
+	for _, field := range argInfo.paramFields {
+		stored := files[field.name]
+		if !field.stream || len(stored) == 0 {
+			continue
+		}
+		fieldValue := elemValue.FieldByIndex(field.index)
+		if fieldValue.Type() == storedFileType {
+			fieldValue.Set(reflect.ValueOf(stored[0]))
+		} else {
+			fieldValue.Set(reflect.ValueOf(stored))
+		}
+	}
+
+	//body已经读取完，合并form以及url上的值按照form tag绑定
+	query := gctx.Request.URL.Query()
+	for k, v := range form {
+		if err := a.checkSliceLen(k, len(v)+len(query[k])); err != nil {
+			return err
+		}
+	}
+	for k, v := range form {
+		query[k] = append(append([]string{}, v...), query[k]...)
+	}
+	lookup := func(name string) ([]byte, bool, error) {
+		data, ok := parts[name]
+		return data, ok, nil
+	}
+	if err := mapForm(elemValuePrt.Interface(), query); err != nil {
+		return err
+	}
+	return a.bindPartFields(argInfo, elemValue, lookup)
+}
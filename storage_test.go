@@ -0,0 +1,192 @@
+package gbinding
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+//newStreamRequest 先写入普通字段，再写入文件
+func newStreamRequest(fields map[string]string, files ...uploadFile) *http.Request {
+	body := &strings.Builder{}
+	writer := multipart.NewWriter(body)
+	for k, v := range fields {
+		_ = writer.WriteField(k, v)
+	}
+	for _, file := range files {
+		part, _ := writer.CreateFormFile(file.field, file.filename)
+		_, _ = part.Write([]byte(file.content))
+	}
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body.String()))
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestBindingAndInvoke_storedFile(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+	type upload struct {
+		Title       string        `form:"title" binding:"required"`
+		Avatar      *StoredFile   `form:"avatar" accept:"image/png"`
+		Attachments []*StoredFile `form:"attachments" maxSize:"8"`
+	}
+	dir := t.TempDir()
+	storedCount := func() int {
+		entries, _ := ioutil.ReadDir(dir)
+		return len(entries)
+	}
+
+	t.Run("store", func(t *testing.T) {
+		var got upload
+		handler := BindingAndInvoke(func(u *upload) error {
+			got = *u
+			return nil
+		}, WithStorage(NewLocalStorage(dir)))
+		serve(http.MethodPost, "/", handler, newStreamRequest(map[string]string{"title": "hello"},
+			uploadFile{"avatar", "a.png", pngData},
+			uploadFile{"attachments[]", "b.txt", "bbb"},
+			uploadFile{"attachments[]", "c.txt", "ccc"},
+		))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got.Title, "hello")
+		assert.Equal(t, got.Avatar.ContentType, "image/png")
+		assert.Equal(t, got.Avatar.Size, int64(len(pngData)))
+		assert.Equal(t, got.Avatar.SHA256, sha256Hex(pngData))
+		assert.Equal(t, len(got.Attachments), 2)
+		assert.Equal(t, got.Attachments[1].Filename, "c.txt")
+		data, err := ioutil.ReadFile(got.Attachments[1].Key)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, string(data), "ccc")
+		assert.Equal(t, storedCount(), 3)
+	})
+
+	t.Run("constraint", func(t *testing.T) {
+		before := storedCount()
+		handler := BindingAndInvoke(func(u upload) error {
+			return nil
+		}, WithStorage(NewLocalStorage(dir)))
+		serve(http.MethodPost, "/", handler, newStreamRequest(map[string]string{"title": "hello"},
+			uploadFile{"avatar", "a.png", pngData},
+			uploadFile{"attachments", "b.txt", "too large file"},
+		))
+		var fieldErrs FieldErrors
+		assert.Equal(t, errors.As(lastErr, &fieldErrs), true)
+		assert.Equal(t, fieldErrs, FieldErrors{{Field: "attachments", Source: File, Rule: "maxSize", Param: "8", Value: "b.txt"}})
+		//失败时已经保存的文件会被删除
+		assert.Equal(t, storedCount(), before)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		before := storedCount()
+		handler := BindingAndInvoke(func(u upload) error {
+			return nil
+		}, WithStorage(NewLocalStorage(dir)))
+		serve(http.MethodPost, "/", handler, newStreamRequest(nil, uploadFile{"avatar", "a.png", pngData}))
+		assert.NotEqual(t, lastErr, nil)
+		assert.Equal(t, storedCount(), before)
+	})
+
+	t.Run("validate after stream", func(t *testing.T) {
+		type validated struct {
+			Title  string      `form:"title" validate:"required"`
+			Avatar *StoredFile `form:"avatar"`
+		}
+		before := storedCount()
+		handler := BindingAndInvoke(func(v validated) error {
+			return nil
+		}, WithStorage(NewLocalStorage(dir)))
+		serve(http.MethodPost, "/", handler, newStreamRequest(nil, uploadFile{"avatar", "a.png", pngData}))
+		var statusErr *StatusError
+		assert.Equal(t, errors.As(lastErr, &statusErr), true)
+		assert.Equal(t, statusErr.StatusCode, http.StatusBadRequest)
+		//保存之后的校验失败时同样删除
+		assert.Equal(t, storedCount(), before)
+	})
+
+	t.Run("with file header", func(t *testing.T) {
+		type mixed struct {
+			Avatar *StoredFile           `form:"avatar"`
+			Cover  *multipart.FileHeader `form:"cover"`
+		}
+		defer func() {
+			assert.Equal(t, recover().(string), "struct:gbinding.mixed field:Cover *StoredFile fields are streamed, can't use with *multipart.FileHeader field")
+		}()
+		BindingAndInvoke(func(m mixed) error {
+			return nil
+		}, WithStorage(NewLocalStorage(t.TempDir())))
+	})
+
+	t.Run("without storage", func(t *testing.T) {
+		defer func() {
+			assert.Equal(t, recover().(string), "struct:gbinding.upload has *StoredFile field, must set WithStorage")
+		}()
+		BindingAndInvoke(func(u upload) error {
+			return nil
+		})
+	})
+}
+
+func TestBindingAndInvoke_uploadStream(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+	dir := t.TempDir()
+
+	var (
+		fields []string
+		stored []*StoredFile
+	)
+	handler := BindingAndInvoke(func(stream *UploadStream) error {
+		for {
+			part, err := stream.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if part.FileName() == "" {
+				data, _ := ioutil.ReadAll(part)
+				fields = append(fields, part.FormName()+"="+string(data))
+				continue
+			}
+			file, err := stream.Store(part)
+			if err != nil {
+				return err
+			}
+			stored = append(stored, file)
+		}
+	}, WithStorage(NewLocalStorage(dir)), WithFileConstraint("", FileConstraint{Extensions: []string{".txt"}}))
+
+	serve(http.MethodPost, "/", handler, newStreamRequest(map[string]string{"title": "hello"},
+		uploadFile{"file", "a.txt", "aaa"},
+	))
+	assert.Equal(t, lastErr, nil)
+	assert.Equal(t, fields, []string{"title=hello"})
+	assert.Equal(t, len(stored), 1)
+	assert.Equal(t, stored[0].SHA256, sha256Hex("aaa"))
+
+	serve(http.MethodPost, "/", handler, newStreamRequest(nil, uploadFile{"file", "a.exe", "aaa"}))
+	var fieldErrs FieldErrors
+	assert.Equal(t, errors.As(lastErr, &fieldErrs), true)
+	assert.Equal(t, fieldErrs[0].Rule, "ext")
+}
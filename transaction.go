@@ -0,0 +1,72 @@
+package gbinding
+
+import (
+	"log"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Tx 请求范围内的事务，*sql.Tx 可以直接使用
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+type transaction struct {
+	txType reflect.Type
+	begin  func(gctx *gin.Context) (Tx, reflect.Value, error)
+}
+
+//WithTransaction 调用函数前开启事务，并注入到类型为T的参数上，函数返回nil时提交，返回错误或者panic时回滚。
+//提交在ResponseHandler之前完成，提交失败时会作为错误返回
+func WithTransaction[T Tx](beginner func(gctx *gin.Context) (T, error)) CallOption {
+	if beginner == nil {
+		log.Panic("transaction beginner can't null")
+	}
+	txInfo := &transaction{
+		txType: reflect.TypeOf((*T)(nil)).Elem(),
+		begin: func(gctx *gin.Context) (Tx, reflect.Value, error) {
+			tx, err := beginner(gctx)
+			if err != nil {
+				return nil, reflect.Value{}, err
+			}
+			return tx, reflect.ValueOf(&tx).Elem(), nil
+		},
+	}
+	return func(c *callFunc) {
+		c.txInfo = txInfo
+	}
+}
+
+//invokeInTx 开启事务后调用函数，函数返回的错误保留在结果中，返回的错误是开启或者提交事务的错误
+func (c *callFunc) invokeInTx(gctx *gin.Context, argValues []reflect.Value) (result []reflect.Value, err error) {
+	tx, txValue, err := c.txInfo.begin(gctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range c.asInfo.args {
+		if c.asInfo.args[i].IsTx() {
+			argValues[i] = txValue
+		}
+	}
+
+	returned := false
+	defer func() {
+		if returned {
+			return
+		}
+		//函数panic时回滚后继续panic，交给gin的Recovery处理
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+	result = c.callFnValue.Call(argValues)
+	returned = true
+	if errValue := result[len(result)-1]; !errValue.IsNil() {
+		_ = tx.Rollback()
+		return result, nil
+	}
+	return result, tx.Commit()
+}
@@ -0,0 +1,521 @@
+package gbinding
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+//tus协议处理失败的原因，TusHandler会转换成对应的状态码
+var (
+	ErrTusNotFound       = errors.New("tus: upload not found")
+	ErrTusOffsetMismatch = errors.New("tus: upload offset mismatch")
+	ErrTusSizeExceeded   = errors.New("tus: upload size exceeded")
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination"
+	//tusContentType PATCH请求的Content-Type
+	tusContentType = "application/offset+octet-stream"
+	//tusUploadKey 以及 tusUploadPtrKey 完成的上传保存在gin.Context上的key
+	tusUploadKey    = "gbinding.tusUpload"
+	tusUploadPtrKey = "gbinding.tusUploadPtr"
+)
+
+//TusUpload 一个可以断点续传的上传
+type TusUpload struct {
+	ID string
+	//Length 文件的总大小
+	Length int64
+	//Offset 已经接收的字节数
+	Offset   int64
+	Metadata map[string]string
+	//Key 在TusStore中定位文件的key，本地存储时为文件路径
+	Key string
+}
+
+//CompletedUpload 接收完成的上传，作为TusHandler完成时调用的函数的参数
+type CompletedUpload struct {
+	ID       string
+	Size     int64
+	Metadata map[string]string
+	Key      string
+}
+
+//TusStore 断点续传的存储
+type TusStore interface {
+	//Create 创建上传，需要设置upload的ID以及Key
+	Create(ctx context.Context, upload *TusUpload) error
+	//Get 获取上传的进度，不存在时返回ErrTusNotFound
+	Get(ctx context.Context, id string) (*TusUpload, error)
+	//Append 从offset开始追加数据，返回新的offset，读取中断时已经写入的数据需要保留
+	Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error)
+	//Delete 删除上传以及已经接收的数据
+	Delete(ctx context.Context, id string) error
+}
+
+//LocalTusStore 保存到本地目录的TusStore，数据保存在 dir/id，信息保存在 dir/id.json
+type LocalTusStore struct {
+	dir   string
+	mu    sync.Mutex
+	locks map[string]*tusLock
+}
+
+//tusLock 一个上传的锁，refs为持有以及等待该锁的数量，为0时从locks中删除
+type tusLock struct {
+	sync.Mutex
+	refs int
+}
+
+//NewLocalTusStore 保存到dir目录，目录需要已经存在
+func NewLocalTusStore(dir string) *LocalTusStore {
+	return &LocalTusStore{dir: filepath.Clean(dir), locks: make(map[string]*tusLock)}
+}
+
+//tusInfo 保存在 id.json 中的信息，offset通过数据文件的大小计算
+type tusInfo struct {
+	Length   int64             `json:"length"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+//lock 同一个上传的写入以及删除需要串行，没有请求使用时删除该上传的锁
+func (s *LocalTusStore) lock(id string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[id]
+	if !ok {
+		l = &tusLock{}
+		s.locks[id] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(s.locks, id)
+		}
+		s.mu.Unlock()
+	}
+}
+
+//path id由客户端提供，只接受Create生成的hex，避免访问目录以外的文件
+func (s *LocalTusStore) path(id string) (string, error) {
+	if len(id) != 32 {
+		return "", ErrTusNotFound
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", ErrTusNotFound
+	}
+	return filepath.Join(s.dir, id), nil
+}
+
+func (s *LocalTusStore) Create(ctx context.Context, upload *TusUpload) error {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	id := hex.EncodeToString(buf)
+	path := filepath.Join(s.dir, id)
+	info, err := json.Marshal(tusInfo{Length: upload.Length, Metadata: upload.Metadata})
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".json", info, 0o600); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, nil, 0o600); err != nil {
+		_ = os.Remove(path + ".json")
+		return err
+	}
+	upload.ID = id
+	upload.Key = path
+	return nil
+}
+
+func (s *LocalTusStore) Get(ctx context.Context, id string) (*TusUpload, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path + ".json")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrTusNotFound
+		}
+		return nil, err
+	}
+	var info tusInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrTusNotFound
+		}
+		return nil, err
+	}
+	return &TusUpload{ID: id, Length: info.Length, Offset: stat.Size(), Metadata: info.Metadata, Key: path}, nil
+}
+
+func (s *LocalTusStore) Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	unlock := s.lock(id)
+	defer unlock()
+	upload, err := s.Get(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if upload.Offset != offset {
+		return upload.Offset, ErrTusOffsetMismatch
+	}
+	f, err := os.OpenFile(upload.Key, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return offset, err
+	}
+	written, err := io.Copy(f, io.LimitReader(r, upload.Length-offset))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	offset += written
+	if err != nil {
+		return offset, err
+	}
+	//超过Upload-Length的数据不保存
+	if n, _ := r.Read(make([]byte, 1)); n > 0 {
+		return offset, ErrTusSizeExceeded
+	}
+	return offset, nil
+}
+
+func (s *LocalTusStore) Delete(ctx context.Context, id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	unlock := s.lock(id)
+	defer unlock()
+	if err := os.Remove(path + ".json"); err != nil {
+		if os.IsNotExist(err) {
+			return ErrTusNotFound
+		}
+		return err
+	}
+	return os.Remove(path)
+}
+
+//TusOption TusHandler的选项
+type TusOption func(c *tusConfig)
+
+type tusConfig struct {
+	maxSize     int64
+	callOptions []CallOption
+}
+
+//TusMaxSize 单个上传的最大字节数，创建时Upload-Length超过时返回413
+func TusMaxSize(size int64) TusOption {
+	return func(c *tusConfig) {
+		c.maxSize = size
+	}
+}
+
+//TusCallOptions 调用完成时的函数使用的选项，例如WithRoles。权限以及WithMaxBodySize等请求限制在每个tus请求上都会检查，
+//没有权限的客户端不能创建以及写入上传
+func TusCallOptions(ops ...CallOption) TusOption {
+	return func(c *tusConfig) {
+		c.callOptions = append(c.callOptions, ops...)
+	}
+}
+
+//TusHandler 实现tus 1.0.0协议的断点续传，支持creation以及termination扩展。
+//协议上的错误直接返回对应的状态码，完成时调用的函数返回的错误交给ResponseHandler处理
+type TusHandler struct {
+	store    TusStore
+	maxSize  int64
+	complete *callFunc
+}
+
+//NewTusHandler onComplete和BindingAndInvoke的函数一样按照类型注入参数，另外可以使用CompletedUpload或者*CompletedUpload参数，
+//例如 func(ctx context.Context, f gbinding.CompletedUpload) error。
+//完成时的函数返回错误后客户端可以重新发送最后的PATCH再次触发，所以需要是幂等的
+func NewTusHandler(store TusStore, onComplete interface{}, opts ...TusOption) *TusHandler {
+	if store == nil {
+		log.Panic("tus store can't null")
+	}
+	config := &tusConfig{}
+	for i := range opts {
+		opts[i](config)
+	}
+	ops := append([]CallOption{
+		WithContextValue[CompletedUpload](tusUploadKey),
+		WithContextValue[*CompletedUpload](tusUploadPtrKey),
+	}, config.callOptions...)
+	return &TusHandler{
+		store:    store,
+		maxSize:  config.maxSize,
+		complete: newCallFunc(onComplete, ops...),
+	}
+}
+
+//Mount 在path上注册创建的路由，在 path/:id 上注册上传以及查询进度的路由
+func (h *TusHandler) Mount(routes gin.IRoutes, path string) {
+	path = strings.TrimSuffix(path, "/")
+	routes.OPTIONS(path, h.options)
+	routes.POST(path, h.create)
+	routes.HEAD(path+"/:id", h.head)
+	routes.PATCH(path+"/:id", h.patch)
+	routes.DELETE(path+"/:id", h.terminate)
+}
+
+//checkVersion 除了OPTIONS以外的请求都必须带有Tus-Resumable，同时检查TusCallOptions中的权限以及请求限制
+func (h *TusHandler) checkVersion(gctx *gin.Context) bool {
+	gctx.Header("Tus-Resumable", tusVersion)
+	if gctx.GetHeader("Tus-Resumable") != tusVersion {
+		gctx.Header("Tus-Version", tusVersion)
+		gctx.AbortWithStatus(http.StatusPreconditionFailed)
+		return false
+	}
+	if err := h.complete.authorize(gctx); err != nil {
+		h.fail(gctx, err)
+		return false
+	}
+	if err := h.complete.asInfo.checkLimits(gctx); err != nil {
+		h.fail(gctx, err)
+		return false
+	}
+	return true
+}
+
+//abort 将store的错误转换成状态码
+func (h *TusHandler) abort(gctx *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrTusNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrTusOffsetMismatch):
+		status = http.StatusConflict
+	case errors.Is(err, ErrTusSizeExceeded), errors.Is(err, ErrBodyTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	}
+	_ = gctx.Error(err)
+	gctx.AbortWithStatus(status)
+}
+
+func (h *TusHandler) options(gctx *gin.Context) {
+	gctx.Header("Tus-Resumable", tusVersion)
+	gctx.Header("Tus-Version", tusVersion)
+	gctx.Header("Tus-Extension", tusExtensions)
+	if h.maxSize > 0 {
+		gctx.Header("Tus-Max-Size", strconv.FormatInt(h.maxSize, 10))
+	}
+	gctx.Status(http.StatusNoContent)
+}
+
+func (h *TusHandler) create(gctx *gin.Context) {
+	if !h.checkVersion(gctx) {
+		return
+	}
+	if gctx.GetHeader("Upload-Defer-Length") != "" {
+		gctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	length, err := strconv.ParseInt(gctx.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		gctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if h.maxSize > 0 && length > h.maxSize {
+		gctx.AbortWithStatus(http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := parseTusMetadata(gctx.GetHeader("Upload-Metadata"))
+	if err != nil {
+		gctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	upload := &TusUpload{Length: length, Metadata: metadata}
+	if err := h.store.Create(gctx, upload); err != nil {
+		h.abort(gctx, err)
+		return
+	}
+	gctx.Header("Location", strings.TrimSuffix(gctx.Request.URL.Path, "/")+"/"+upload.ID)
+	//空文件创建时就已经完成
+	if length == 0 {
+		h.finish(gctx, upload, http.StatusCreated)
+		return
+	}
+	gctx.Status(http.StatusCreated)
+}
+
+func (h *TusHandler) head(gctx *gin.Context) {
+	if !h.checkVersion(gctx) {
+		return
+	}
+	upload, err := h.store.Get(gctx, gctx.Param("id"))
+	if err != nil {
+		h.abort(gctx, err)
+		return
+	}
+	gctx.Header("Cache-Control", "no-store")
+	gctx.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	gctx.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if len(upload.Metadata) > 0 {
+		gctx.Header("Upload-Metadata", formatTusMetadata(upload.Metadata))
+	}
+	gctx.Status(http.StatusOK)
+}
+
+func (h *TusHandler) patch(gctx *gin.Context) {
+	if !h.checkVersion(gctx) {
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(gctx.GetHeader("Content-Type")); mediaType != tusContentType {
+		gctx.AbortWithStatus(http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(gctx.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		gctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	upload, err := h.store.Get(gctx, gctx.Param("id"))
+	if err != nil {
+		h.abort(gctx, err)
+		return
+	}
+	if offset != upload.Offset {
+		h.abort(gctx, ErrTusOffsetMismatch)
+		return
+	}
+	//Content-Length已知时，超过剩余长度的请求在写入之前拒绝
+	if gctx.Request.ContentLength > upload.Length-offset {
+		gctx.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		h.abort(gctx, ErrTusSizeExceeded)
+		return
+	}
+	upload.Offset, err = h.store.Append(gctx, upload.ID, offset, gctx.Request.Body)
+	gctx.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	//长度未知的请求写满之后才能发现多余的数据，此时上传已经完成，仍然需要调用完成时的函数
+	overflow := errors.Is(err, ErrTusSizeExceeded) && upload.Offset == upload.Length
+	if err != nil && !overflow {
+		h.abort(gctx, bodyLimitError(gctx, err))
+		return
+	}
+	if upload.Offset == upload.Length {
+		status := http.StatusNoContent
+		if overflow {
+			status = http.StatusRequestEntityTooLarge
+		}
+		h.finish(gctx, upload, status)
+		return
+	}
+	gctx.Status(http.StatusNoContent)
+}
+
+func (h *TusHandler) terminate(gctx *gin.Context) {
+	if !h.checkVersion(gctx) {
+		return
+	}
+	if err := h.store.Delete(gctx, gctx.Param("id")); err != nil {
+		h.abort(gctx, err)
+		return
+	}
+	gctx.Status(http.StatusNoContent)
+}
+
+//finish 调用完成时的函数，成功时返回status
+func (h *TusHandler) finish(gctx *gin.Context, upload *TusUpload, status int) {
+	completed := CompletedUpload{ID: upload.ID, Size: upload.Length, Metadata: upload.Metadata, Key: upload.Key}
+	gctx.Set(tusUploadKey, completed)
+	gctx.Set(tusUploadPtrKey, &completed)
+	//不经过handlerFunc，TusCallOptions中的WithLanguage在这里设置
+	if h.complete.language != "" {
+		gctx.Set(languageKey, h.complete.language)
+	}
+	if _, err := h.complete.invoke(gctx); err != nil {
+		h.fail(gctx, err)
+		return
+	}
+	gctx.Status(status)
+}
+
+//fail 完成时的函数以及权限检查失败时交给ResponseHandler处理，ResponseHandler没有写入响应时，
+//StatusError以及AuthError返回对应的状态码，其他错误返回500，避免客户端认为已经完成
+func (h *TusHandler) fail(gctx *gin.Context, err error) {
+	h.complete.rsInfo.Return(gctx, nil, err)
+	if gctx.Writer.Written() {
+		return
+	}
+	status := http.StatusInternalServerError
+	var (
+		statusErr *StatusError
+		authErr   *AuthError
+	)
+	switch {
+	case errors.As(err, &statusErr):
+		status = statusErr.StatusCode
+	case errors.As(err, &authErr):
+		status = authErr.StatusCode
+	}
+	gctx.AbortWithStatus(status)
+}
+
+//parseTusMetadata 解析 key base64(value),key2 形式的Upload-Metadata
+func parseTusMetadata(header string) (map[string]string, error) {
+	if strings.TrimSpace(header) == "" {
+		return nil, nil
+	}
+	metadata := make(map[string]string)
+	for _, item := range strings.Split(header, ",") {
+		kv := strings.Fields(item)
+		switch len(kv) {
+		case 1:
+			metadata[kv[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("metadata %s: %w", kv[0], err)
+			}
+			metadata[kv[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("invalid metadata %s", item)
+		}
+	}
+	return metadata, nil
+}
+
+//formatTusMetadata 按照key排序，保证结果稳定
+func formatTusMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	items := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if metadata[k] == "" {
+			items = append(items, k)
+			continue
+		}
+		items = append(items, k+" "+base64.StdEncoding.EncodeToString([]byte(metadata[k])))
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,274 @@
+package gbinding
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func Test_parseTusMetadata(t *testing.T) {
+	metadata, err := parseTusMetadata("filename " + base64.StdEncoding.EncodeToString([]byte("a.txt")) + ",is_confidential")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, metadata, map[string]string{"filename": "a.txt", "is_confidential": ""})
+	assert.Equal(t, formatTusMetadata(metadata), "filename YS50eHQ=,is_confidential")
+
+	_, err = parseTusMetadata("filename !!!")
+	assert.NotEqual(t, err, nil)
+}
+
+func TestLocalTusStore_lock(t *testing.T) {
+	store := NewLocalTusStore(t.TempDir())
+	ctx := context.Background()
+	upload := &TusUpload{Length: 8}
+	assert.Equal(t, store.Create(ctx, upload), nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			//每个goroutine写入一个字节，offset不一致时重试
+			for {
+				current, err := store.Get(ctx, upload.ID)
+				if err != nil {
+					return
+				}
+				if _, err := store.Append(ctx, upload.ID, current.Offset, strings.NewReader("a")); !errors.Is(err, ErrTusOffsetMismatch) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	got, err := store.Get(ctx, upload.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, got.Offset, int64(8))
+	//完成之后没有请求使用时不再保留锁
+	assert.Equal(t, len(store.locks), 0)
+}
+
+func TestTusHandler(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+		if err != nil {
+			ctx.Status(http.StatusInternalServerError)
+		}
+	})
+
+	var (
+		completed []CompletedUpload
+		content   string
+		failed    bool
+	)
+	handler := NewTusHandler(NewLocalTusStore(t.TempDir()), func(ctx context.Context, f CompletedUpload) error {
+		if failed {
+			return errors.New("process failed")
+		}
+		data, err := ioutil.ReadFile(f.Key)
+		content = string(data)
+		completed = append(completed, f)
+		return err
+	}, TusMaxSize(64))
+	r := gin.New()
+	handler.Mount(r.Group("/api"), "/files/")
+
+	do := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	patchHeaders := func(offset string) map[string]string {
+		return map[string]string{"Content-Type": "application/offset+octet-stream", "Upload-Offset": offset}
+	}
+
+	w := do(http.MethodOptions, "/api/files", "", nil)
+	assert.Equal(t, w.Code, http.StatusNoContent)
+	assert.Equal(t, w.Header().Get("Tus-Extension"), "creation,termination")
+	assert.Equal(t, w.Header().Get("Tus-Max-Size"), "64")
+
+	w = do(http.MethodPost, "/api/files", "", map[string]string{"Upload-Length": "65"})
+	assert.Equal(t, w.Code, http.StatusRequestEntityTooLarge)
+
+	w = do(http.MethodPost, "/api/files", "", map[string]string{
+		"Upload-Length":   "11",
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("a.txt")),
+	})
+	assert.Equal(t, w.Code, http.StatusCreated)
+	location := w.Header().Get("Location")
+	assert.Equal(t, strings.HasPrefix(location, "/api/files/"), true)
+
+	w = do(http.MethodHead, location, "", nil)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("Upload-Offset"), "0")
+	assert.Equal(t, w.Header().Get("Upload-Length"), "11")
+	assert.Equal(t, w.Header().Get("Upload-Metadata"), "filename YS50eHQ=")
+
+	w = do(http.MethodPatch, location, "hello ", patchHeaders("0"))
+	assert.Equal(t, w.Code, http.StatusNoContent)
+	assert.Equal(t, w.Header().Get("Upload-Offset"), "6")
+
+	//offset不一致时拒绝
+	w = do(http.MethodPatch, location, "world", patchHeaders("0"))
+	assert.Equal(t, w.Code, http.StatusConflict)
+	w = do(http.MethodPatch, location, "world", map[string]string{"Upload-Offset": "6"})
+	assert.Equal(t, w.Code, http.StatusUnsupportedMediaType)
+
+	//完成时的函数失败，重新发送最后的PATCH再次触发
+	failed = true
+	w = do(http.MethodPatch, location, "world", patchHeaders("6"))
+	assert.Equal(t, w.Code, http.StatusInternalServerError)
+	assert.Equal(t, lastErr.Error(), "process failed")
+	failed = false
+	w = do(http.MethodPatch, location, "", patchHeaders("11"))
+	assert.Equal(t, w.Code, http.StatusNoContent)
+	assert.Equal(t, w.Header().Get("Upload-Offset"), "11")
+	assert.Equal(t, len(completed), 1)
+	assert.Equal(t, completed[0].Size, int64(11))
+	assert.Equal(t, completed[0].Metadata, map[string]string{"filename": "a.txt"})
+	assert.Equal(t, content, "hello world")
+
+	//超过Upload-Length的PATCH在写入之前拒绝
+	w = do(http.MethodPost, "/api/files", "", map[string]string{"Upload-Length": "5"})
+	location = w.Header().Get("Location")
+	w = do(http.MethodPatch, location, "hello!!", patchHeaders("0"))
+	assert.Equal(t, w.Code, http.StatusRequestEntityTooLarge)
+	assert.Equal(t, w.Header().Get("Upload-Offset"), "0")
+	assert.Equal(t, len(completed), 1)
+
+	//长度未知时写满之后才发现多余的数据，上传已经完成
+	req := httptest.NewRequest(http.MethodPatch, location, strings.NewReader("hello!!"))
+	req.ContentLength = -1
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	for k, v := range patchHeaders("0") {
+		req.Header.Set(k, v)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, http.StatusRequestEntityTooLarge)
+	assert.Equal(t, w.Header().Get("Upload-Offset"), "5")
+	assert.Equal(t, len(completed), 2)
+	assert.Equal(t, content, "hello")
+
+	w = do(http.MethodDelete, location, "", nil)
+	assert.Equal(t, w.Code, http.StatusNoContent)
+	w = do(http.MethodHead, location, "", nil)
+	assert.Equal(t, w.Code, http.StatusNotFound)
+	w = do(http.MethodHead, "/api/files/..", "", nil)
+	assert.Equal(t, w.Code, http.StatusNotFound)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/files", nil)
+	req.Header.Set("Upload-Length", "1")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, http.StatusPreconditionFailed)
+	assert.Equal(t, w.Header().Get("Tus-Version"), "1.0.0")
+
+	//没有ResponseHandler时完成失败也不能返回成功
+	t.Run("complete failed without response", func(t *testing.T) {
+		global := responseFn
+		responseFn = nil
+		defer func() {
+			responseFn = global
+		}()
+		handler := NewTusHandler(NewLocalTusStore(t.TempDir()), func(f CompletedUpload) error {
+			return errors.New("process failed")
+		})
+		r := gin.New()
+		handler.Mount(r.Group("/api"), "/files/")
+		req := httptest.NewRequest(http.MethodPost, "/api/files", nil)
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		req.Header.Set("Upload-Length", "0")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, w.Code, http.StatusInternalServerError)
+	})
+
+	//每个tus请求都检查权限，没有权限时不能创建以及写入
+	t.Run("roles", func(t *testing.T) {
+		RegisterPrincipal(func(gctx *gin.Context) (*testPrincipal, error) {
+			switch gctx.GetHeader("X-User") {
+			case "admin":
+				return &testPrincipal{name: "admin", roles: []string{"admin"}}, nil
+			case "guest":
+				return &testPrincipal{name: "guest", roles: []string{"guest"}}, nil
+			}
+			return nil, errors.New("no user")
+		})
+		defer func() {
+			principal = nil
+		}()
+		dir := t.TempDir()
+		handler := NewTusHandler(NewLocalTusStore(dir), func(f CompletedUpload) error {
+			return nil
+		}, TusCallOptions(WithRoles("admin")))
+		r := gin.New()
+		handler.Mount(r.Group("/api"), "/files/")
+		do := func(method, path, user, body string, headers map[string]string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Tus-Resumable", "1.0.0")
+			req.Header.Set("X-User", user)
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+		createHeaders := map[string]string{"Upload-Length": "5"}
+		assert.Equal(t, do(http.MethodPost, "/api/files", "", "", createHeaders).Code, http.StatusUnauthorized)
+		assert.Equal(t, do(http.MethodPost, "/api/files", "guest", "", createHeaders).Code, http.StatusForbidden)
+		files, _ := ioutil.ReadDir(dir)
+		assert.Equal(t, len(files), 0)
+
+		w := do(http.MethodPost, "/api/files", "admin", "", createHeaders)
+		assert.Equal(t, w.Code, http.StatusCreated)
+		location := w.Header().Get("Location")
+		assert.Equal(t, do(http.MethodPatch, location, "guest", "hello", patchHeaders("0")).Code, http.StatusForbidden)
+		assert.Equal(t, do(http.MethodHead, location, "guest", "", nil).Code, http.StatusForbidden)
+		assert.Equal(t, do(http.MethodDelete, location, "guest", "", nil).Code, http.StatusForbidden)
+		w = do(http.MethodHead, location, "admin", "", nil)
+		assert.Equal(t, w.Code, http.StatusOK)
+		assert.Equal(t, w.Header().Get("Upload-Offset"), "0")
+	})
+
+	t.Run("language", func(t *testing.T) {
+		var message string
+		SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+			if err != nil {
+				message = Message(ctx, err)
+			}
+		})
+		handler := NewTusHandler(NewLocalTusStore(t.TempDir()), func(f CompletedUpload) error {
+			return fieldErrorsResult(FieldErrors{{Field: "filename", Rule: "required"}})
+		}, TusCallOptions(WithLanguage("zh")))
+		r := gin.New()
+		handler.Mount(r.Group("/api"), "/files/")
+		req := httptest.NewRequest(http.MethodPost, "/api/files", nil)
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		req.Header.Set("Upload-Length", "1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		req = httptest.NewRequest(http.MethodPatch, w.Header().Get("Location"), strings.NewReader("a"))
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		for k, v := range patchHeaders("0") {
+			req.Header.Set(k, v)
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, message, "filename为必填字段")
+	})
+}
@@ -0,0 +1,272 @@
+package gbinding
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var fileHeadersType = reflect.TypeOf([]*multipart.FileHeader{})
+
+//isFileField 字段类型是*multipart.FileHeader或者[]*multipart.FileHeader
+func isFileField(fieldType reflect.Type) bool {
+	return fieldType == fileHeaderType || fieldType == fileHeadersType
+}
+
+//multipartFiles 取出name对应的全部文件，同时支持 name，name[] 以及 name[0] 的形式，name[0]按照下标排序
+func multipartFiles(form *multipart.Form, name string) []*multipart.FileHeader {
+	name = strings.TrimSuffix(name, "[]")
+	files := append([]*multipart.FileHeader{}, form.File[name]...)
+	files = append(files, form.File[name+"[]"]...)
+
+	type indexFiles struct {
+		index int
+		files []*multipart.FileHeader
+	}
+	var indexed []indexFiles
+	for k, v := range form.File {
+		if !strings.HasPrefix(k, name+"[") || !strings.HasSuffix(k, "]") {
+			continue
+		}
+		index, err := strconv.Atoi(k[len(name)+1 : len(k)-1])
+		if err != nil {
+			continue
+		}
+		indexed = append(indexed, indexFiles{index: index, files: v})
+	}
+	sort.Slice(indexed, func(i, j int) bool {
+		return indexed[i].index < indexed[j].index
+	})
+	for i := range indexed {
+		files = append(files, indexed[i].files...)
+	}
+	return files
+}
+
+//bindFileField 绑定结构体上的文件字段并检查约束，不是multipart请求或者没有该文件时保持零值
+func (a *argsInfo) bindFileField(gctx *gin.Context, field reflect.Value, paramField paramField) (FieldErrors, error) {
+	form, err := gctx.MultipartForm()
+	if err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	files := multipartFiles(form, paramField.name)
+	if len(files) == 0 {
+		return nil, nil
+	}
+	if err := a.checkSliceLen(paramField.name, len(files)); err != nil {
+		return nil, err
+	}
+	fieldErrs, err := checkFiles(paramField.name, a.fileConstraint(paramField.name, paramField.fileConstraint), files)
+	if err != nil {
+		return nil, err
+	}
+	if field.Type() == fileHeaderType {
+		field.Set(reflect.ValueOf(files[0]))
+	} else {
+		field.Set(reflect.ValueOf(files))
+	}
+	return fieldErrs, nil
+}
+
+//FileConstraint 上传文件的约束，零值表示不限制
+type FileConstraint struct {
+	//MaxSize 单个文件的最大字节数
+	MaxSize int64
+	//MaxFiles 同一个名称下文件的最大数量
+	MaxFiles int
+	//Accept 允许的MIME类型，支持 image/* 的形式，通过文件内容检测，不使用客户端提供的Content-Type
+	Accept []string
+	//Extensions 允许的扩展名，例如 .png，不区分大小写
+	Extensions []string
+}
+
+//merge 用other中非零的约束覆盖当前的约束
+func (c FileConstraint) merge(other FileConstraint) FileConstraint {
+	if other.MaxSize > 0 {
+		c.MaxSize = other.MaxSize
+	}
+	if other.MaxFiles > 0 {
+		c.MaxFiles = other.MaxFiles
+	}
+	if len(other.Accept) > 0 {
+		c.Accept = other.Accept
+	}
+	if len(other.Extensions) > 0 {
+		c.Extensions = other.Extensions
+	}
+	return c
+}
+
+//WithFileConstraint 设置name上传文件的约束，name为空时对全部文件生效，结构体字段上的tag优先
+func WithFileConstraint(name string, constraint FileConstraint) CallOption {
+	return func(c *callFunc) {
+		if c.asInfo.fileConstraints == nil {
+			c.asInfo.fileConstraints = make(map[string]FileConstraint)
+		}
+		c.asInfo.fileConstraints[name] = constraint
+	}
+}
+
+//fileConstraint name上生效的约束，优先级为 tag > WithFileConstraint(name) > WithFileConstraint("")
+func (a *argsInfo) fileConstraint(name string, tagConstraint *FileConstraint) FileConstraint {
+	constraint := a.fileConstraints[""]
+	if named, ok := a.fileConstraints[strings.TrimSuffix(name, "[]")]; ok && name != "" {
+		constraint = constraint.merge(named)
+	}
+	if tagConstraint != nil {
+		constraint = constraint.merge(*tagConstraint)
+	}
+	return constraint
+}
+
+//fileTagConstraint 解析字段上的maxSize,maxFiles,accept以及ext tag，没有时返回nil
+func fileTagConstraint(structType reflect.Type, field reflect.StructField) *FileConstraint {
+	var (
+		constraint FileConstraint
+		hasTag     bool
+	)
+	if value, ok := field.Tag.Lookup("maxSize"); ok {
+		size, err := parseSize(value)
+		if err != nil {
+			log.Panicf("struct:%s field:%s maxSize tag expect size like 5MB but get %s", structType.String(), field.Name, value)
+		}
+		constraint.MaxSize = size
+		hasTag = true
+	}
+	if value, ok := field.Tag.Lookup("maxFiles"); ok {
+		count, err := strconv.Atoi(value)
+		if err != nil || count <= 0 {
+			log.Panicf("struct:%s field:%s maxFiles tag expect positive int but get %s", structType.String(), field.Name, value)
+		}
+		constraint.MaxFiles = count
+		hasTag = true
+	}
+	if value, ok := field.Tag.Lookup("accept"); ok {
+		constraint.Accept = splitList(value)
+		hasTag = true
+	}
+	if value, ok := field.Tag.Lookup("ext"); ok {
+		constraint.Extensions = splitList(value)
+		hasTag = true
+	}
+	if !hasTag {
+		return nil
+	}
+	return &constraint
+}
+
+//parseSize 解析 1024，512KB，5MB，1GB 形式的大小
+func parseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	unit := int64(1)
+	for _, suffix := range []struct {
+		name string
+		size int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, suffix.name) {
+			unit = suffix.size
+			value = strings.TrimSpace(strings.TrimSuffix(value, suffix.name))
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid size %s", value)
+	}
+	return size * unit, nil
+}
+
+//splitList 拆分逗号分隔的列表，去掉空白
+func splitList(value string) []string {
+	result := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+//sniffContentType 读取文件的前512个字节检测MIME类型
+func sniffContentType(file *multipart.FileHeader) (string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(reader, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	if err != nil {
+		return "", err
+	}
+	return mediaType, nil
+}
+
+//acceptContentType contentType是否在允许的列表中
+func acceptContentType(accept []string, contentType string) bool {
+	for i := range accept {
+		pattern := strings.ToLower(accept[i])
+		if pattern == "*/*" || pattern == contentType {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+//acceptExtension 文件名的扩展名是否在允许的列表中
+func acceptExtension(extensions []string, filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for i := range extensions {
+		if strings.ToLower("."+strings.TrimPrefix(extensions[i], ".")) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+//checkFiles 检查name上的全部文件，返回全部不满足约束的错误
+func checkFiles(name string, constraint FileConstraint, files []*multipart.FileHeader) (FieldErrors, error) {
+	var fieldErrs FieldErrors
+	if constraint.MaxFiles > 0 && len(files) > constraint.MaxFiles {
+		fieldErrs = append(fieldErrs, &FieldError{Field: name, Source: File, Rule: "maxFiles", Param: strconv.Itoa(constraint.MaxFiles), Value: strconv.Itoa(len(files))})
+	}
+	for _, file := range files {
+		if constraint.MaxSize > 0 && file.Size > constraint.MaxSize {
+			fieldErrs = append(fieldErrs, &FieldError{Field: name, Source: File, Rule: "maxSize", Param: strconv.FormatInt(constraint.MaxSize, 10), Value: file.Filename})
+		}
+		if len(constraint.Extensions) > 0 && !acceptExtension(constraint.Extensions, file.Filename) {
+			fieldErrs = append(fieldErrs, &FieldError{Field: name, Source: File, Rule: "ext", Param: strings.Join(constraint.Extensions, ","), Value: file.Filename})
+		}
+		if len(constraint.Accept) > 0 {
+			contentType, err := sniffContentType(file)
+			if err != nil {
+				return nil, err
+			}
+			if !acceptContentType(constraint.Accept, contentType) {
+				fieldErrs = append(fieldErrs, &FieldError{Field: name, Source: File, Rule: "accept", Param: strings.Join(constraint.Accept, ","), Value: contentType})
+			}
+		}
+	}
+	return fieldErrs, nil
+}
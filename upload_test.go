@@ -0,0 +1,244 @@
+package gbinding
+
+import (
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+//pngData png文件头，用于检测MIME类型
+const pngData = "\x89PNG\r\n\x1a\n0000"
+
+//uploadFile multipart中的一个文件
+type uploadFile struct {
+	field, filename, content string
+}
+
+func newUploadRequest(files ...uploadFile) *http.Request {
+	body := &strings.Builder{}
+	writer := multipart.NewWriter(body)
+	for _, file := range files {
+		part, _ := writer.CreateFormFile(file.field, file.filename)
+		_, _ = part.Write([]byte(file.content))
+	}
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body.String()))
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func Test_parseSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"1024", 1024},
+		{"10B", 10},
+		{"512kb", 512 << 10},
+		{"5MB", 5 << 20},
+		{"1 GB", 1 << 30},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.value)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, got, tt.want)
+	}
+	_, err := parseSize("5XB")
+	assert.NotEqual(t, err, nil)
+}
+
+func Test_acceptContentType(t *testing.T) {
+	assert.Equal(t, acceptContentType([]string{"image/png"}, "image/png"), true)
+	assert.Equal(t, acceptContentType([]string{"image/*"}, "image/jpeg"), true)
+	assert.Equal(t, acceptContentType([]string{"image/*"}, "text/plain"), false)
+	assert.Equal(t, acceptExtension([]string{"png", ".JPG"}, "a.jpg"), true)
+	assert.Equal(t, acceptExtension([]string{".png"}, "a.png.exe"), false)
+}
+
+func TestBindingAndInvoke_fileConstraint(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+	fieldErrors := func(t *testing.T) FieldErrors {
+		var statusErr *StatusError
+		assert.Equal(t, errors.As(lastErr, &statusErr), true)
+		assert.Equal(t, statusErr.StatusCode, http.StatusBadRequest)
+		var fieldErrs FieldErrors
+		assert.Equal(t, errors.As(lastErr, &fieldErrs), true)
+		return fieldErrs
+	}
+
+	t.Run("struct tag", func(t *testing.T) {
+		type upload struct {
+			Avatar      *multipart.FileHeader   `form:"avatar" accept:"image/png,image/jpeg" maxSize:"1KB"`
+			Attachments []*multipart.FileHeader `form:"attachments" maxFiles:"1" ext:".pdf"`
+		}
+		handler := BindingAndInvoke(func(u upload) error {
+			return nil
+		})
+		serve(http.MethodPost, "/", handler, newUploadRequest(
+			uploadFile{"avatar", "a.png", pngData},
+			uploadFile{"attachments", "b.pdf", "pdf"},
+		))
+		assert.Equal(t, lastErr, nil)
+
+		//客户端声明的文件名以及Content-Type都不可信，以内容为准
+		serve(http.MethodPost, "/", handler, newUploadRequest(
+			uploadFile{"avatar", "a.png", "plain text"},
+			uploadFile{"attachments", "b.pdf", "pdf"},
+			uploadFile{"attachments", "c.exe", "exe"},
+		))
+		assert.Equal(t, fieldErrors(t), FieldErrors{
+			{Field: "avatar", Source: File, Rule: "accept", Param: "image/png,image/jpeg", Value: "text/plain"},
+			{Field: "attachments", Source: File, Rule: "maxFiles", Param: "1", Value: "2"},
+			{Field: "attachments", Source: File, Rule: "ext", Param: ".pdf", Value: "c.exe"},
+		})
+	})
+
+	t.Run("option", func(t *testing.T) {
+		handler := BindingAndInvoke(func(file *multipart.FileHeader) error {
+			return nil
+		}, WithFileName("file"), WithFileConstraint("", FileConstraint{MaxSize: 4}))
+		serve(http.MethodPost, "/", handler, newUploadRequest(uploadFile{"file", "a.txt", "hello"}))
+		assert.Equal(t, fieldErrors(t), FieldErrors{
+			{Field: "file", Source: File, Rule: "maxSize", Param: "4", Value: "a.txt"},
+		})
+	})
+
+	t.Run("multipart form", func(t *testing.T) {
+		handler := BindingAndInvoke(func(form *multipart.Form) error {
+			return nil
+		}, WithFileConstraint("images", FileConstraint{Accept: []string{"image/*"}}))
+		serve(http.MethodPost, "/", handler, newUploadRequest(
+			uploadFile{"images", "a.png", pngData},
+			uploadFile{"other", "b.txt", "text"},
+		))
+		assert.Equal(t, lastErr, nil)
+
+		serve(http.MethodPost, "/", handler, newUploadRequest(uploadFile{"images", "b.png", "text"}))
+		assert.Equal(t, fieldErrors(t), FieldErrors{
+			{Field: "images", Source: File, Rule: "accept", Param: "image/*", Value: "text/plain"},
+		})
+	})
+
+	t.Run("invalid tag", func(t *testing.T) {
+		type upload struct {
+			Avatar *multipart.FileHeader `maxSize:"big"`
+		}
+		defer func() {
+			assert.Equal(t, recover().(string), "struct:gbinding.upload field:Avatar maxSize tag expect size like 5MB but get big")
+		}()
+		BindingAndInvoke(func(u upload) error {
+			return nil
+		})
+	})
+}
+
+func TestBindingAndInvoke_multipartPart(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+	type meta struct {
+		Name string   `json:"name" binding:"required"`
+		Tags []string `json:"tags"`
+	}
+	type upload struct {
+		Meta   *meta                 `part:"meta"`
+		Avatar *multipart.FileHeader `form:"avatar"`
+		Note   string                `form:"note"`
+	}
+	newReq := func(metaJSON string, asFile bool) *http.Request {
+		body := &strings.Builder{}
+		writer := multipart.NewWriter(body)
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", "application/json")
+		header.Set("Content-Disposition", `form-data; name="meta"`)
+		if asFile {
+			header.Set("Content-Disposition", `form-data; name="meta"; filename="meta.json"`)
+		}
+		part, _ := writer.CreatePart(header)
+		_, _ = part.Write([]byte(metaJSON))
+		//和嵌套字段同名的form字段不会覆盖part
+		_ = writer.WriteField("Name", "overwrite")
+		_ = writer.WriteField("note", "hi")
+		file, _ := writer.CreateFormFile("avatar", "a.png")
+		_, _ = file.Write([]byte(pngData))
+		_ = writer.Close()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body.String()))
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+
+	for _, asFile := range []bool{false, true} {
+		var got upload
+		handler := BindingAndInvoke(func(u upload) error {
+			got = u
+			return nil
+		})
+		serve(http.MethodPost, "/", handler, newReq(`{"name":"a","tags":["x","y"]}`, asFile))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, *got.Meta, meta{Name: "a", Tags: []string{"x", "y"}})
+		assert.Equal(t, got.Avatar.Filename, "a.png")
+		assert.Equal(t, got.Note, "hi")
+	}
+
+	//只有part以及文件字段，不需要gin绑定
+	t.Run("part and file only", func(t *testing.T) {
+		type avatarUpload struct {
+			Meta   *meta                 `part:"meta"`
+			Avatar *multipart.FileHeader `form:"avatar"`
+		}
+		var got avatarUpload
+		handler := BindingAndInvoke(func(u avatarUpload) error {
+			got = u
+			return nil
+		})
+		serve(http.MethodPost, "/", handler, newReq(`{"name":"a"}`, false))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, *got.Meta, meta{Name: "a"})
+		assert.Equal(t, got.Avatar.Filename, "a.png")
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		handler := BindingAndInvoke(func(u upload) error {
+			return nil
+		})
+		//part解码之后只校验一次
+		serve(http.MethodPost, "/", handler, newReq(`{"tags":["x"]}`, false))
+		var fieldErrs FieldErrors
+		assert.Equal(t, errors.As(lastErr, &fieldErrs), true)
+		assert.Equal(t, fieldErrs, FieldErrors{{Field: "meta.name", Source: Part, Rule: "required", Value: ""}})
+		serve(http.MethodPost, "/", handler, newReq(`{"name":`, false))
+		assert.Equal(t, strings.HasPrefix(lastErr.Error(), "part meta:"), true)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		type streamUpload struct {
+			Meta   meta        `part:"meta"`
+			Avatar *StoredFile `form:"avatar"`
+		}
+		var got streamUpload
+		handler := BindingAndInvoke(func(u streamUpload) error {
+			got = u
+			return nil
+		}, WithStorage(NewLocalStorage(t.TempDir())))
+		serve(http.MethodPost, "/", handler, newReq(`{"name":"a"}`, true))
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got.Meta.Name, "a")
+		assert.Equal(t, got.Avatar.Filename, "a.png")
+
+		serve(http.MethodPost, "/", handler, newReq(`{"tags":["x"]}`, true))
+		var fieldErrs FieldErrors
+		assert.Equal(t, errors.As(lastErr, &fieldErrs), true)
+		assert.Equal(t, fieldErrs, FieldErrors{{Field: "meta.name", Source: Part, Rule: "required", Value: ""}})
+	})
+}
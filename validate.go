@@ -0,0 +1,239 @@
+package gbinding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+//validateTag 全部来源绑定完成之后统一校验使用的tag，binding tag也在同一时间使用gin的校验器校验
+const validateTag = "validate"
+
+//nameTags 校验失败时字段在请求中的名称，按照顺序取第一个设置了的tag
+var nameTags = []string{"uri", "header", "ctx", "auth", "claim", "part", "form", "json"}
+
+var validate = newValidate()
+
+func newValidate() *validator.Validate {
+	v := validator.New()
+	v.SetTagName(validateTag)
+	v.RegisterTagNameFunc(requestFieldName)
+	return v
+}
+
+//RegisterValidation 注册validate tag上使用的自定义校验规则
+func RegisterValidation(tag string, fn validator.Func) {
+	if err := validate.RegisterValidation(tag, fn); err != nil {
+		log.Panicf("register validation %s failed: %v", tag, err)
+	}
+}
+
+//requestFieldName 字段在请求中的名称
+func requestFieldName(field reflect.StructField) string {
+	for _, tag := range nameTags {
+		if name := tagName(field, tag); name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+//normalizer 绑定完成之后、校验之前整理字段，例如去掉空格、统一大小写
+type normalizer interface {
+	Normalize()
+}
+
+//selfValidator 结构体自己实现的校验，用于跨字段的规则，例如结束时间必须晚于开始时间
+type selfValidator interface {
+	Validate() error
+}
+
+//contextValidator 需要请求context的校验，例如查询数据库
+type contextValidator interface {
+	Validate(ctx context.Context) error
+}
+
+//checkStruct 依次执行sanitize tag的转换，Normalize，validate tag校验以及Validate，Validate返回的错误作为400返回
+func (a *argsInfo) checkStruct(gctx *gin.Context, argInfo *argTypeInfo, elemValuePrt reflect.Value) error {
+	sanitizeStruct(elemValuePrt.Elem(), argInfo.sanitizers)
+	obj := elemValuePrt.Interface()
+	if n, ok := obj.(normalizer); ok {
+		n.Normalize()
+	}
+	if err := a.validateFields(gctx, argInfo, elemValuePrt); err != nil {
+		return err
+	}
+	var err error
+	switch v := obj.(type) {
+	case selfValidator:
+		err = v.Validate()
+	case contextValidator:
+		err = v.Validate(gctx.Request.Context())
+	}
+	if err == nil {
+		return nil
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return err
+	}
+	return &StatusError{StatusCode: http.StatusBadRequest, Err: err}
+}
+
+//validateFields 所有来源都绑定到结构体之后按照binding tag，validate tag以及Enum校验，全部不满足的字段一起返回
+func (a *argsInfo) validateFields(gctx *gin.Context, argInfo *argTypeInfo, elemValuePrt reflect.Value) error {
+	obj := elemValuePrt.Interface()
+	var fieldErrs FieldErrors
+	if v := binding.Validator; v != nil {
+		errs, err := a.validationErrors(gctx, argInfo, v.ValidateStruct(obj), true)
+		if err != nil {
+			return err
+		}
+		fieldErrs = append(fieldErrs, errs...)
+	}
+	errs, err := a.validationErrors(gctx, argInfo, validate.Struct(obj), false)
+	if err != nil {
+		return err
+	}
+	fieldErrs = append(fieldErrs, errs...)
+	fieldErrs = append(fieldErrs, a.enumErrors(gctx, argInfo, elemValuePrt.Elem())...)
+	return fieldErrorsResult(fieldErrs)
+}
+
+//validationErrors 将校验器返回的错误转换成FieldErrors，gin的校验器使用字段名称作为Namespace，需要转换成请求中的名称
+func (a *argsInfo) validationErrors(gctx *gin.Context, argInfo *argTypeInfo, err error, goNames bool) (FieldErrors, error) {
+	if err == nil {
+		return nil, nil
+	}
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil, err
+	}
+	fieldErrs := make(FieldErrors, 0, len(validationErrs))
+	for _, validationErr := range validationErrs {
+		field := trimNamespace(validationErr.Namespace())
+		if goNames {
+			field = requestPath(argInfo.GetBasicType(), trimNamespace(validationErr.StructNamespace()))
+		}
+		fieldErrs = append(fieldErrs, &FieldError{
+			Field:  field,
+			Source: a.fieldSource(gctx, argInfo, topFieldName(validationErr.StructNamespace())),
+			Rule:   validationErr.Tag(),
+			Param:  validationErr.Param(),
+			Value:  fmt.Sprint(validationErr.Value()),
+		})
+	}
+	return fieldErrs, nil
+}
+
+//requestPath 将字段名称组成的路径转换成请求中的名称，例如 Items[0].Name 返回 items[0].name
+func requestPath(structType reflect.Type, structPath string) string {
+	parts := strings.Split(structPath, ".")
+	for i, part := range parts {
+		name, index := part, ""
+		if j := strings.IndexByte(part, '['); j >= 0 {
+			name, index = part[:j], part[j:]
+		}
+		structType = elemType(structType)
+		if structType.Kind() != reflect.Struct {
+			break
+		}
+		field, ok := structType.FieldByName(name)
+		if !ok {
+			break
+		}
+		parts[i] = requestFieldName(field) + index
+		structType = field.Type
+	}
+	return strings.Join(parts, ".")
+}
+
+//trimNamespace 去掉最前面的结构体名称，例如 user.items[0].name 返回 items[0].name
+func trimNamespace(namespace string) string {
+	if i := strings.IndexByte(namespace, '.'); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+//topFieldName 第一层字段的名称，例如 user.Items[0].Name 返回 Items
+func topFieldName(structNamespace string) string {
+	name := trimNamespace(structNamespace)
+	if i := strings.IndexAny(name, ".["); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+//fieldSource 按照第一层字段判断来源，没有tag以及名称选项的字段由gin从query或者body绑定
+func (a *argsInfo) fieldSource(gctx *gin.Context, argInfo *argTypeInfo, name string) Source {
+	field, ok := argInfo.GetBasicType().FieldByName(name)
+	if !ok {
+		return Body
+	}
+	for i := range argInfo.paramFields {
+		if reflect.DeepEqual(argInfo.paramFields[i].index, field.Index) {
+			return argInfo.paramFields[i].source
+		}
+	}
+	if argInfo.primary {
+		namedSources := []struct {
+			names  []string
+			source Source
+		}{
+			{a.pathNames, Path},
+			{a.headerNames, Header},
+			{a.cookieNames, Cookie},
+			{a.contextKeys, Context},
+		}
+		for _, named := range namedSources {
+			for i := range named.names {
+				if a.filedNameIsEqual(field.Name, named.names[i]) {
+					return named.source
+				}
+			}
+		}
+	}
+	if gctx.Request.Method == http.MethodGet || gctx.Request.Method == http.MethodHead {
+		return Query
+	}
+	return Body
+}
+
+//walkStructFields 遍历结构体上导出的字段，嵌套的结构体，结构体指针以及slice中的字段先遍历，结果作为nested传给visit，
+//visit返回false的字段不保留。递归的结构体只遍历一次
+func walkStructFields[T any](structType reflect.Type, visit func(owner reflect.Type, field reflect.StructField, nested []T) (T, bool)) []T {
+	return walkFields(structType, visit, map[reflect.Type]bool{})
+}
+
+func walkFields[T any](structType reflect.Type, visit func(owner reflect.Type, field reflect.StructField, nested []T) (T, bool), visiting map[reflect.Type]bool) []T {
+	if visiting[structType] {
+		return nil
+	}
+	visiting[structType] = true
+	defer delete(visiting, structType)
+
+	var fields []T
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		var nested []T
+		if elem := elemType(field.Type); elem.Kind() == reflect.Struct && elem != fileHeaderType.Elem() {
+			nested = walkFields(elem, visit, visiting)
+		}
+		if result, ok := visit(structType, field, nested); ok {
+			fields = append(fields, result)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,224 @@
+package gbinding
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/assert/v2"
+	"github.com/go-playground/validator/v10"
+)
+
+func TestBindingAndInvoke_validate(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+	type item struct {
+		Name string `json:"name" validate:"required"`
+	}
+	type request struct {
+		ID    int64  `uri:"id" validate:"gt=0"`
+		Token string `header:"X-Token" validate:"required"`
+		Name  string `json:"name" validate:"min=3"`
+		Items []item `json:"items" validate:"dive"`
+	}
+	handler := BindingAndInvoke(func(r request) error {
+		return nil
+	})
+	newReq := func(path, body string, token string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("X-Token", token)
+		}
+		return req
+	}
+
+	serve(http.MethodPost, "/:id", handler, newReq("/1", `{"name":"abc","items":[{"name":"a"}]}`, "t"))
+	assert.Equal(t, lastErr, nil)
+
+	//path以及header上的值和body一样校验，全部错误一起返回
+	serve(http.MethodPost, "/:id", handler, newReq("/0", `{"name":"ab","items":[{"name":"a"},{}]}`, ""))
+	var statusErr *StatusError
+	assert.Equal(t, errors.As(lastErr, &statusErr), true)
+	assert.Equal(t, statusErr.StatusCode, http.StatusBadRequest)
+	var fieldErrs FieldErrors
+	assert.Equal(t, errors.As(lastErr, &fieldErrs), true)
+	assert.Equal(t, fieldErrs, FieldErrors{
+		{Field: "id", Source: Path, Rule: "gt", Param: "0", Value: "0"},
+		{Field: "X-Token", Source: Header, Rule: "required", Value: ""},
+		{Field: "name", Source: Body, Rule: "min", Param: "3", Value: "ab"},
+		{Field: "items[1].name", Source: Body, Rule: "required", Value: ""},
+	})
+
+	t.Run("custom rule", func(t *testing.T) {
+		RegisterValidation("even", func(fl validator.FieldLevel) bool {
+			return fl.Field().Int()%2 == 0
+		})
+		type query struct {
+			Page int `form:"page" validate:"even"`
+		}
+		handler := BindingAndInvoke(func(q query) error {
+			return nil
+		})
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?page=2", nil))
+		assert.Equal(t, lastErr, nil)
+		serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?page=3", nil))
+		fieldErrs = nil
+		assert.Equal(t, errors.As(lastErr, &fieldErrs), true)
+		assert.Equal(t, fieldErrs, FieldErrors{{Field: "page", Source: Query, Rule: "even", Value: "3"}})
+	})
+
+	//binding tag在全部来源绑定完之后校验，不经过gin绑定的字段也可以使用
+	t.Run("binding tag", func(t *testing.T) {
+		type request struct {
+			ID     int64  `binding:"required"`
+			Tenant string `ctx:"tenant" binding:"required"`
+			Token  string `header:"X-Token" binding:"required"`
+			Name   string `json:"name" binding:"required"`
+		}
+		r := gin.New()
+		r.POST("/:id", func(gctx *gin.Context) {
+			if tenant := gctx.GetHeader("X-Tenant"); tenant != "" {
+				gctx.Set("tenant", tenant)
+			}
+		}, BindingAndInvoke(func(r request) error {
+			return nil
+		}, WithPathNames("id")))
+		do := func(path, tenant, token string) {
+			req := newReq(path, `{"name":"a"}`, token)
+			req.Header.Set("X-Tenant", tenant)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+		}
+		do("/1", "t", "x")
+		assert.Equal(t, lastErr, nil)
+
+		do("/0", "t", "")
+		fieldErrs = nil
+		assert.Equal(t, errors.As(lastErr, &fieldErrs), true)
+		assert.Equal(t, fieldErrs, FieldErrors{
+			{Field: "ID", Source: Path, Rule: "required", Value: "0"},
+			{Field: "X-Token", Source: Header, Rule: "required", Value: ""},
+		})
+	})
+
+	//解码时不调用binding.Validator，也不替换gin全局的校验器
+	t.Run("gin validator", func(t *testing.T) {
+		original := binding.Validator
+		defer func() { binding.Validator = original }()
+		v := &countValidator{StructValidator: original}
+		binding.Validator = v
+		type request struct {
+			Name string `form:"name" xml:"name" binding:"required"`
+			Age  int    `form:"age" xml:"age"`
+		}
+		var got request
+		handler := BindingAndInvoke(func(r request) error {
+			got = r
+			return nil
+		})
+		assert.Equal(t, binding.Validator, v)
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("name=tom&age=3"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		serve(http.MethodPost, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, request{Name: "tom", Age: 3})
+		assert.Equal(t, v.count, 1)
+
+		req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("<request><name>tom</name><age>5</age></request>"))
+		req.Header.Set("Content-Type", "application/xml")
+		serve(http.MethodPost, "/", handler, req)
+		assert.Equal(t, lastErr, nil)
+		assert.Equal(t, got, request{Name: "tom", Age: 5})
+		assert.Equal(t, v.count, 2)
+		assert.Equal(t, binding.Validator, v)
+	})
+}
+
+//countValidator 记录gin的校验器被调用的次数
+type countValidator struct {
+	binding.StructValidator
+	count int
+}
+
+func (v *countValidator) ValidateStruct(obj interface{}) error {
+	v.count++
+	return v.StructValidator.ValidateStruct(obj)
+}
+
+type period struct {
+	Name  string    `form:"name" validate:"required"`
+	Start time.Time `form:"start" time_format:"2006-01-02"`
+	End   time.Time `form:"end" time_format:"2006-01-02"`
+}
+
+func (p *period) Normalize() {
+	p.Name = strings.TrimSpace(p.Name)
+}
+
+func (p period) Validate() error {
+	if !p.End.After(p.Start) {
+		return errors.New("end must after start")
+	}
+	return nil
+}
+
+type owned struct {
+	Owner string `form:"owner"`
+}
+
+func (o owned) Validate(ctx context.Context) error {
+	if o.Owner != ctx.Value("user") {
+		return &StatusError{StatusCode: http.StatusForbidden, Err: errors.New("not owner")}
+	}
+	return nil
+}
+
+func TestBindingAndInvoke_validateHooks(t *testing.T) {
+	var lastErr error
+	SetGlobalResponse(func(ctx *gin.Context, data interface{}, err error) {
+		lastErr = err
+	})
+
+	var got *period
+	handler := BindingAndInvoke(func(p *period) error {
+		got = p
+		return nil
+	})
+	serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?name=%20a%20&start=2021-01-01&end=2021-01-02", nil))
+	assert.Equal(t, lastErr, nil)
+	assert.Equal(t, got.Name, "a")
+
+	//Normalize之后再按照tag校验
+	serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?name=%20&start=2021-01-01&end=2021-01-02", nil))
+	var fieldErrs FieldErrors
+	assert.Equal(t, errors.As(lastErr, &fieldErrs), true)
+
+	serve(http.MethodGet, "/", handler, httptest.NewRequest(http.MethodGet, "/?name=a&start=2021-01-02&end=2021-01-01", nil))
+	var statusErr *StatusError
+	assert.Equal(t, errors.As(lastErr, &statusErr), true)
+	assert.Equal(t, statusErr.StatusCode, http.StatusBadRequest)
+	assert.Equal(t, statusErr.Err.Error(), "end must after start")
+
+	//Validate返回的StatusError保持原样
+	handler = BindingAndInvoke(func(o owned) error {
+		return nil
+	})
+	req := httptest.NewRequest(http.MethodGet, "/?owner=b", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "user", "a"))
+	serve(http.MethodGet, "/", handler, req)
+	assert.Equal(t, errors.As(lastErr, &statusErr), true)
+	assert.Equal(t, statusErr.StatusCode, http.StatusForbidden)
+	req = httptest.NewRequest(http.MethodGet, "/?owner=a", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "user", "a"))
+	serve(http.MethodGet, "/", handler, req)
+	assert.Equal(t, lastErr, nil)
+}